@@ -0,0 +1,314 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// How long to wait for the informer caches of a cluster to sync before giving up
+const cacheSyncTimeout = 60 * time.Second
+
+// The informers of a namespace that hasn't been read from for this long are stopped, and started again on its next read
+const namespaceIdleTimeout = 15 * time.Minute
+
+// The most namespaces with informers running at once in a cluster - the least recently read one is stopped to make room for another
+const maxNamespaceCaches = 64
+
+// A long-lived client for a kubeconfig context
+// Reads for deployments, pods and image pull secrets are served from shared informer caches instead of the API server
+type Cluster struct {
+	Clientset *kubernetes.Clientset
+	// Used to apply manifests, which are handled as unstructured objects
+	Dynamic          dynamic.Interface
+	DeploymentLister DeploymentLister
+	PodLister        PodLister
+	// Only contains secrets of type kubernetes.io/dockerconfigjson - which is what image pull secrets are created as
+	SecretLister SecretLister
+
+	kubeContext string
+	// The informer caches of each namespace that has been read from recently
+	namespaces      map[string]*namespaceEntry
+	namespacesMutex sync.Mutex
+}
+
+// The informer caches of one namespace
+type namespaceCache struct {
+	deployments appslisters.DeploymentNamespaceLister
+	pods        corelisters.PodNamespaceLister
+	secrets     corelisters.SecretNamespaceLister
+	// Stop the informers
+	stop func()
+}
+
+type namespaceEntry struct {
+	lazyEntry[*namespaceCache]
+	// Guarded by the cluster's namespacesMutex
+	lastUsed time.Time
+}
+
+// Stop the informers of an entry that's been removed from the cluster
+// This waits for an attempt in progress, so informers that are still starting are stopped once they're up
+func (e *namespaceEntry) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.created {
+		e.value.stop()
+	}
+}
+
+// How long a context that failed to start is remembered before it's tried again - this doubles with each failure, up to maxFailureBackoff
+const (
+	minFailureBackoff = 5 * time.Second
+	maxFailureBackoff = 5 * time.Minute
+)
+
+// A value that's created on first use and then shared
+// A failure is kept for a backoff period, so a slow or unreachable cluster isn't retried by every request
+type lazyEntry[T any] struct {
+	mu       sync.Mutex
+	value    T
+	created  bool
+	err      error
+	retryAt  time.Time
+	failures int
+}
+
+// Get the value, creating it if it hasn't been yet - concurrent callers wait for the same attempt rather than making their own
+func (e *lazyEntry[T]) get(create func() (T, error)) (T, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.created {
+		return e.value, nil
+	}
+	if e.err != nil && time.Now().Before(e.retryAt) {
+		return e.value, e.err
+	}
+
+	value, err := create()
+	if err != nil {
+		backoff := minFailureBackoff << e.failures
+		if backoff > maxFailureBackoff || backoff <= 0 {
+			backoff = maxFailureBackoff
+		} else {
+			e.failures++
+		}
+		e.err, e.retryAt = err, time.Now().Add(backoff)
+		return e.value, err
+	}
+	e.value, e.created, e.err = value, true, nil
+
+	return value, nil
+}
+
+// Clusters are cached per context so the clientset is only created once for each cluster
+// The mutex only guards the map - each entry is created under its own lock, so a slow context doesn't hold up requests for the others
+var (
+	clusters      = map[string]*lazyEntry[*Cluster]{}
+	clustersMutex sync.Mutex
+)
+
+// Get the cluster for a kubeconfig context - an empty context name uses the current context
+// The context is looked up in the kubeconfig loaded at startup, and the first call for a context creates its clientset
+func ClusterForContext(kubeContext string) (*Cluster, error) {
+	// Resolve the current context to its name, so it shares its entry with requests that name it explicitly
+	kubeContext, err := resolveContext(kubeContext)
+	if err != nil {
+		return nil, err
+	}
+
+	clustersMutex.Lock()
+	entry, ok := clusters[kubeContext]
+	if !ok {
+		entry = &lazyEntry[*Cluster]{}
+		clusters[kubeContext] = entry
+	}
+	clustersMutex.Unlock()
+
+	return entry.get(func() (*Cluster, error) { return newCluster(kubeContext) })
+}
+
+// Create the clientset for a kubeconfig context
+// The informers are started per namespace, on the first read from that namespace
+func newCluster(kubeContext string) (*Cluster, error) {
+	config, err := restConfigForContext(kubeContext)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &Cluster{
+		Clientset:   clientset,
+		Dynamic:     dynamicClient,
+		kubeContext: kubeContext,
+		namespaces:  map[string]*namespaceEntry{},
+	}
+	cluster.DeploymentLister = deploymentLister{cluster}
+	cluster.PodLister = podLister{cluster}
+	cluster.SecretLister = secretLister{cluster}
+	// Clusters are kept for the life of the process, and so is this
+	go cluster.evictIdleNamespaces()
+
+	return cluster, nil
+}
+
+// Get the informer caches for a namespace, starting them and waiting for them to sync on first use
+func (c *Cluster) namespaceCache(namespace string) (*namespaceCache, error) {
+	c.namespacesMutex.Lock()
+	entry, ok := c.namespaces[namespace]
+	if !ok {
+		if len(c.namespaces) >= maxNamespaceCaches {
+			c.evictLeastRecentlyUsed()
+		}
+		entry = &namespaceEntry{}
+		c.namespaces[namespace] = entry
+	}
+	entry.lastUsed = time.Now()
+	c.namespacesMutex.Unlock()
+
+	return entry.get(func() (*namespaceCache, error) { return c.startNamespaceInformers(namespace) })
+}
+
+// Stop the informers of namespaces that haven't been read from for namespaceIdleTimeout
+// Failed entries are removed the same way, so namespaces that were only tried once don't build up
+func (c *Cluster) evictIdleNamespaces() {
+	ticker := time.NewTicker(namespaceIdleTimeout / 4)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.namespacesMutex.Lock()
+		idle := []string{}
+		for name, entry := range c.namespaces {
+			if time.Since(entry.lastUsed) > namespaceIdleTimeout {
+				idle = append(idle, name)
+			}
+		}
+		for _, name := range idle {
+			c.removeNamespace(name)
+		}
+		c.namespacesMutex.Unlock()
+	}
+}
+
+// Remove the least recently read namespace to make room for another - the caller holds namespacesMutex
+func (c *Cluster) evictLeastRecentlyUsed() {
+	var oldestName string
+	var oldest *namespaceEntry
+	for name, entry := range c.namespaces {
+		if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+			oldestName, oldest = name, entry
+		}
+	}
+	if oldest != nil {
+		c.removeNamespace(oldestName)
+	}
+}
+
+// Remove a namespace and stop its informers - the caller holds namespacesMutex
+// The informers are stopped in the background, since an attempt to start them may still be waiting for the caches to sync
+func (c *Cluster) removeNamespace(namespace string) {
+	entry := c.namespaces[namespace]
+	delete(c.namespaces, namespace)
+	zap.L().Info("Removing the informer caches for namespace " + namespace + " in kubeconfig context: " + displayContext(c.kubeContext))
+	go entry.close()
+}
+
+// Start the informers for a namespace and wait for their caches to sync
+// The informers only list and watch the one namespace, so credentials scoped to a namespace - e.g. a ServiceAccount bound with a Role - work as well
+func (c *Cluster) startNamespaceInformers(namespace string) (*namespaceCache, error) {
+	// Check the namespace exists, so a request for a made up namespace doesn't start informers that watch nothing
+	// Credentials scoped to a namespace may not be allowed to get it - the informers' own lists are the check then
+	if _, err := c.Clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{}); err != nil && !apierrors.IsForbidden(err) {
+		return nil, err
+	}
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(c.Clientset, 0, informers.WithNamespace(namespace))
+	secretInformerFactory := informers.NewSharedInformerFactoryWithOptions(c.Clientset, 0, informers.WithNamespace(namespace), informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.FieldSelector = "type=" + string(apiv1.SecretTypeDockerConfigJson)
+	}))
+	deploymentInformer := informerFactory.Apps().V1().Deployments()
+	podInformer := informerFactory.Core().V1().Pods()
+	secretInformer := secretInformerFactory.Core().V1().Secrets()
+
+	// The informers are stopped if they can't sync - the first reason is returned to the client
+	stopCh := make(chan struct{})
+	var stopOnce sync.Once
+	var stopMutex sync.Mutex
+	var stopErr error
+	stop := func(err error) {
+		stopOnce.Do(func() {
+			stopMutex.Lock()
+			stopErr = err
+			stopMutex.Unlock()
+			close(stopCh)
+		})
+	}
+	// A list that's forbidden won't succeed by retrying, so the informers are stopped straight away rather than waiting for the timeout
+	for _, informer := range []cache.SharedIndexInformer{deploymentInformer.Informer(), podInformer.Informer(), secretInformer.Informer()} {
+		if err := informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+				stop(err)
+			}
+			cache.DefaultWatchErrorHandler(r, err)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	zap.L().Info("Starting informers for namespace " + namespace + " in kubeconfig context: " + displayContext(c.kubeContext))
+	informerFactory.Start(stopCh)
+	secretInformerFactory.Start(stopCh)
+
+	// Stop the informers if the caches haven't synced in time - e.g. the cluster is unreachable - so WaitForCacheSync returns
+	timer := time.AfterFunc(cacheSyncTimeout, func() {
+		stop(fmt.Errorf("timed out waiting for the caches to sync for namespace %s in kubeconfig context: %s", namespace, displayContext(c.kubeContext)))
+	})
+	informerFactory.WaitForCacheSync(stopCh)
+	secretInformerFactory.WaitForCacheSync(stopCh)
+	timer.Stop()
+	// If the informers were stopped, they were stopped before or just after the caches synced - either way they won't stay up to date
+	stopMutex.Lock()
+	err := stopErr
+	stopMutex.Unlock()
+	select {
+	case <-stopCh:
+		if err == nil {
+			err = fmt.Errorf("the informers for namespace %s in kubeconfig context %s were stopped", namespace, displayContext(c.kubeContext))
+		}
+		return nil, err
+	default:
+	}
+
+	zap.L().Info("Informer caches synced for namespace " + namespace + " in kubeconfig context: " + displayContext(c.kubeContext))
+
+	return &namespaceCache{
+		deployments: deploymentInformer.Lister().Deployments(namespace),
+		pods:        podInformer.Lister().Pods(namespace),
+		secrets:     secretInformer.Lister().Secrets(namespace),
+		stop:        func() { stop(nil) },
+	}, nil
+}
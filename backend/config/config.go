@@ -1,34 +1,198 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+// Name of the only context available when running in-cluster with the ServiceAccount config
+const InClusterContext = "in-cluster"
+
+// Namespace of the ServiceAccount mounted into the pod
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Returned when a request targets a context that doesn't exist in the kubeconfig
+var ErrContextNotFound = errors.New("kubeconfig context not found")
+
+// A context from the kubeconfig, without any of the user credentials
+type KubeContext struct {
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Current   bool   `json:"current"`
+}
+
 func Int32Ptr(i int32) *int32 { return &i }
 
-func KubeConfig() (*kubernetes.Clientset, error) {
-	var kubeconfig string
-	// Point to the kubeconfig file
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
-		zap.L().Info("kubeconfig location is set to " + kubeconfig)
+func Int64Ptr(i int64) *int64 { return &i }
+
+func BoolPtr(b bool) *bool { return &b }
+
+// Path from the --kubeconfig flag - set at startup with SetKubeConfigPath
+var kubeConfigPath string
+
+// Set the kubeconfig file passed with the --kubeconfig flag, this takes precedence over the KUBECONFIG environment variable
+func SetKubeConfigPath(path string) {
+	kubeConfigPath = path
+}
+
+func kubeConfigLoadingRules() *clientcmd.ClientConfigLoadingRules {
+	// The default rules honour the KUBECONFIG environment variable, including multiple files separated by ":" which are merged,
+	// and otherwise point to $HOME/.kube/config
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeConfigPath
+
+	return loadingRules
+}
+
+// The cluster configuration, loaded once by LoadKubeConfig - requests only look contexts up in it, so the kubeconfig isn't read again for each one
+type loadedKubeConfig struct {
+	inCluster bool
+	// The merged kubeconfig files - empty in-cluster
+	raw clientcmdapi.Config
+	err error
+}
+
+var (
+	kubeConfig     loadedKubeConfig
+	kubeConfigOnce sync.Once
+)
+
+// Load the cluster configuration - this is called at startup, after SetKubeConfigPath, and the result is kept for the life of the process
+// Changes to the kubeconfig files are picked up on restart
+func LoadKubeConfig() error {
+	kubeConfigOnce.Do(func() {
+		kubeConfig.inCluster = useInClusterConfig()
+		if !kubeConfig.inCluster {
+			kubeConfig.raw, kubeConfig.err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(kubeConfigLoadingRules(), &clientcmd.ConfigOverrides{}).RawConfig()
+		}
+	})
+
+	return kubeConfig.err
+}
+
+// Check if the in-cluster ServiceAccount config should be used
+// This is the case when running as a pod and no kubeconfig file is found through the --kubeconfig flag, KUBECONFIG or $HOME/.kube/config
+func useInClusterConfig() bool {
+	if kubeConfigPath != "" {
+		return false
+	}
+	for _, path := range kubeConfigLoadingRules().GetLoadingPrecedence() {
+		if _, err := os.Stat(path); err == nil {
+			return false
+		}
+	}
+
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != "" && os.Getenv("KUBERNETES_SERVICE_PORT") != ""
+}
+
+// Log where the cluster configuration is loaded from
+func LogKubeConfigLocation() {
+	if kubeConfig.inCluster {
+		zap.L().Info("No kubeconfig found, using the in-cluster ServiceAccount config")
+	} else if kubeConfigPath != "" {
+		zap.L().Info("kubeconfig location is set to " + kubeConfigPath + " from the --kubeconfig flag")
 	} else {
-		zap.L().Error("kubeconfig location is not set")
+		zap.L().Info("kubeconfig location is set to " + strings.Join(kubeConfigLoadingRules().GetLoadingPrecedence(), string(filepath.ListSeparator)))
 	}
+}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+// Get the namespace of the ServiceAccount the backend runs as
+func inClusterNamespace() string {
+	namespace, err := os.ReadFile(inClusterNamespaceFile)
 	if err != nil {
-		zap.L().Error(err.Error())
-		panic(err)
+		zap.L().Warn(err.Error())
+		return ""
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	return strings.TrimSpace(string(namespace))
+}
+
+// List the contexts available in the kubeconfig
+func KubeContexts() ([]KubeContext, error) {
+	if err := LoadKubeConfig(); err != nil {
+		return nil, err
+	}
+	// There is no kubeconfig in-cluster - the ServiceAccount config is returned as the only context
+	if kubeConfig.inCluster {
+		return []KubeContext{{Name: InClusterContext, Namespace: inClusterNamespace(), Current: true}}, nil
+	}
+
+	rawConfig := kubeConfig.raw
+
+	contexts := []KubeContext{}
+	for name, context := range rawConfig.Contexts {
+		contexts = append(contexts, KubeContext{
+			Name:      name,
+			Cluster:   context.Cluster,
+			Namespace: context.Namespace,
+			Current:   name == rawConfig.CurrentContext,
+		})
+	}
+	sort.Slice(contexts, func(i, j int) bool { return contexts[i].Name < contexts[j].Name })
+
+	return contexts, nil
+}
+
+// Resolve a kubeconfig context name - an empty name resolves to the current context
+// A context that doesn't exist returns ErrContextNotFound
+func resolveContext(kubeContext string) (string, error) {
+	if err := LoadKubeConfig(); err != nil {
+		return "", err
+	}
+	if kubeConfig.inCluster {
+		if kubeContext != "" && kubeContext != InClusterContext {
+			return "", fmt.Errorf("%w: %s", ErrContextNotFound, kubeContext)
+		}
+
+		return InClusterContext, nil
+	}
+
+	if kubeContext == "" {
+		return kubeConfig.raw.CurrentContext, nil
+	}
+	if _, ok := kubeConfig.raw.Contexts[kubeContext]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrContextNotFound, kubeContext)
+	}
+
+	return kubeContext, nil
+}
+
+// Build the REST config for a kubeconfig context resolved with resolveContext
+func restConfigForContext(kubeContext string) (*rest.Config, error) {
+	if kubeConfig.inCluster {
+		return rest.InClusterConfig()
+	}
+
+	return clientcmd.NewNonInteractiveClientConfig(kubeConfig.raw, kubeContext, &clientcmd.ConfigOverrides{}, kubeConfigLoadingRules()).ClientConfig()
+}
+
+// Get the clientset for the kubeconfig current context
+func KubeConfig() (*kubernetes.Clientset, error) {
+	cluster, err := ClusterForContext("")
+	if err != nil {
+		return nil, err
+	}
+
+	return cluster.Clientset, nil
+}
+
+func displayContext(kubeContext string) string {
+	if kubeContext == "" {
+		return "(current-context)"
+	}
 
-	return clientset, err
+	return kubeContext
 }
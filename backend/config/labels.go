@@ -0,0 +1,8 @@
+package config
+
+const (
+	// Label recorded on every resource this app creates, so it can tell which resources it owns
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// The value of the managed-by label
+	ManagedBy = "kubernetes-client-application"
+)
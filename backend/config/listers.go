@@ -0,0 +1,111 @@
+package config
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// Listers for the informer caches of a cluster
+// Each namespace's informers are started on the first read from it, so a read can fail if they can't sync - e.g. the namespace can't be listed
+
+type DeploymentLister interface {
+	Deployments(namespace string) appslisters.DeploymentNamespaceLister
+}
+
+type PodLister interface {
+	Pods(namespace string) corelisters.PodNamespaceLister
+}
+
+type SecretLister interface {
+	Secrets(namespace string) corelisters.SecretNamespaceLister
+}
+
+type deploymentLister struct{ cluster *Cluster }
+
+func (l deploymentLister) Deployments(namespace string) appslisters.DeploymentNamespaceLister {
+	return deploymentNamespaceLister{l.cluster, namespace}
+}
+
+type deploymentNamespaceLister struct {
+	cluster   *Cluster
+	namespace string
+}
+
+func (l deploymentNamespaceLister) List(selector labels.Selector) ([]*appsv1.Deployment, error) {
+	cache, err := l.cluster.namespaceCache(l.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.deployments.List(selector)
+}
+
+func (l deploymentNamespaceLister) Get(name string) (*appsv1.Deployment, error) {
+	cache, err := l.cluster.namespaceCache(l.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.deployments.Get(name)
+}
+
+type podLister struct{ cluster *Cluster }
+
+func (l podLister) Pods(namespace string) corelisters.PodNamespaceLister {
+	return podNamespaceLister{l.cluster, namespace}
+}
+
+type podNamespaceLister struct {
+	cluster   *Cluster
+	namespace string
+}
+
+func (l podNamespaceLister) List(selector labels.Selector) ([]*apiv1.Pod, error) {
+	cache, err := l.cluster.namespaceCache(l.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.pods.List(selector)
+}
+
+func (l podNamespaceLister) Get(name string) (*apiv1.Pod, error) {
+	cache, err := l.cluster.namespaceCache(l.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.pods.Get(name)
+}
+
+type secretLister struct{ cluster *Cluster }
+
+func (l secretLister) Secrets(namespace string) corelisters.SecretNamespaceLister {
+	return secretNamespaceLister{l.cluster, namespace}
+}
+
+type secretNamespaceLister struct {
+	cluster   *Cluster
+	namespace string
+}
+
+func (l secretNamespaceLister) List(selector labels.Selector) ([]*apiv1.Secret, error) {
+	cache, err := l.cluster.namespaceCache(l.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.secrets.List(selector)
+}
+
+func (l secretNamespaceLister) Get(name string) (*apiv1.Secret, error) {
+	cache, err := l.cluster.namespaceCache(l.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.secrets.Get(name)
+}
@@ -1,17 +1,147 @@
 package config
 
-type CreateDeploymentStruct struct {
-	DeploymentName          string `json:"deploymentName"`
-	DeploymentLabel         string `json:"deploymentLabel"`
+import (
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// A container in a deployment
+// These fields at the top level of CreateDeploymentStruct describe the deployment's main container
+type ContainerStruct struct {
 	ContainerName           string `json:"containerName"`
 	ContainerRegistryServer string `json:"containerRegistryServer"`
 	ContainerImageName      string `json:"containerImageName"`
 	ContainerImageTag       string `json:"containerImageTag"`
 	ContainerPort           string `json:"containerPort"`
-	ReplicaCount            string `json:"replicaCount"`
 	RegistryType            string `json:"registryType"`
 	RegistryUsername        string `json:"registryUsername"`
 	RegistryPassword        string `json:"registryPassword"`
-	CPU                     string `json:"cpu"`
-	Memory                  string `json:"memory"`
+	// Resource limits - quantities such as "500m" or "256Mi"
+	CPU              string `json:"cpu"`
+	Memory           string `json:"memory"`
+	EphemeralStorage string `json:"ephemeralStorage"`
+	// Resource requests - these can't be larger than the limits
+	CPURequest              string `json:"cpuRequest"`
+	MemoryRequest           string `json:"memoryRequest"`
+	EphemeralStorageRequest string `json:"ephemeralStorageRequest"`
+	// containerPort creates a single TCP port named "http" - ports lists more than one port, or ports using other protocols
+	Ports []PortStruct `json:"ports"`
+	// Environment variables - either a literal value, or a valueFrom reference to a ConfigMap key, Secret key or the Downward API
+	Env []apiv1.EnvVar `json:"env"`
+	// Whole ConfigMaps or Secrets to expose as environment variables
+	EnvFrom []apiv1.EnvFromSource `json:"envFrom"`
+	// When set, HTTP GET liveness and readiness probes on this path and containerPort are used for any probe that isn't provided
+	HealthPath     string       `json:"healthPath"`
+	LivenessProbe  *ProbeStruct `json:"livenessProbe"`
+	ReadinessProbe *ProbeStruct `json:"readinessProbe"`
+	StartupProbe   *ProbeStruct `json:"startupProbe"`
+	// Mounts of the deployment's volumes, each with a mountPath and optional subPath and readOnly
+	VolumeMounts []apiv1.VolumeMount `json:"volumeMounts"`
+}
+
+// A port exposed by a container
+type PortStruct struct {
+	// An IANA_SVC_NAME, e.g. "http" or "metrics" - unique within the container
+	Name          string `json:"name"`
+	ContainerPort string `json:"containerPort"`
+	// One of TCP (the default), UDP or SCTP
+	Protocol string `json:"protocol"`
+	HostPort string `json:"hostPort"`
+}
+
+// A liveness, readiness or startup probe
+type ProbeStruct struct {
+	// One of httpGet, tcpSocket, exec or grpc
+	Type string `json:"type"`
+	// The port for httpGet, tcpSocket and grpc probes - a number, or a port name for httpGet and tcpSocket. Defaults to containerPort
+	Port string `json:"port"`
+	// The path and scheme (HTTP or HTTPS) for httpGet probes
+	Path   string `json:"path"`
+	Scheme string `json:"scheme"`
+	// The command for exec probes
+	Command []string `json:"command"`
+	// The gRPC health check service name for grpc probes
+	Service             string `json:"service"`
+	InitialDelaySeconds string `json:"initialDelaySeconds"`
+	PeriodSeconds       string `json:"periodSeconds"`
+	TimeoutSeconds      string `json:"timeoutSeconds"`
+	SuccessThreshold    string `json:"successThreshold"`
+	FailureThreshold    string `json:"failureThreshold"`
+}
+
+type CreateDeploymentStruct struct {
+	DeploymentName  string `json:"deploymentName"`
+	DeploymentLabel string `json:"deploymentLabel"`
+	ReplicaCount    string `json:"replicaCount"`
+	// Labels and annotations for the Deployment itself, and for the pods it creates
+	// The app and owner labels are reserved for the deployment selector, and app.kubernetes.io/managed-by is always set by this app
+	Labels         map[string]string `json:"labels"`
+	Annotations    map[string]string `json:"annotations"`
+	PodLabels      map[string]string `json:"podLabels"`
+	PodAnnotations map[string]string `json:"podAnnotations"`
+	// The main container - its fields are at the top level of the request body
+	ContainerStruct
+	// Additional containers, e.g. sidecars, that run alongside the main container
+	Containers []ContainerStruct `json:"containers"`
+	// Containers that run to completion, in order, before the main containers start - e.g. database migrations
+	InitContainers []ContainerStruct `json:"initContainers"`
+	// Volumes the containers can mount, in the same shape as the Kubernetes pod spec - emptyDir, configMap, secret, persistentVolumeClaim and projected are supported
+	Volumes []apiv1.Volume `json:"volumes"`
+	// Scheduling - tolerations, affinity and topologySpreadConstraints are in the same shape as the Kubernetes pod spec
+	// A topology spread constraint without a labelSelector spreads the deployment's own pods
+	NodeSelector              map[string]string                `json:"nodeSelector"`
+	Tolerations               []apiv1.Toleration               `json:"tolerations"`
+	Affinity                  *apiv1.Affinity                  `json:"affinity"`
+	TopologySpreadConstraints []apiv1.TopologySpreadConstraint `json:"topologySpreadConstraints"`
+	PriorityClassName         string                           `json:"priorityClassName"`
+	// Security settings applied to the pod and every container
+	SecurityContext *SecurityContextStruct `json:"securityContext"`
+	// Rollout strategy - either RollingUpdate (the default) or Recreate
+	// maxSurge and maxUnavailable only apply to RollingUpdate, and are either a number of pods or a percentage, e.g. "25%"
+	Strategy                string `json:"strategy"`
+	MaxSurge                string `json:"maxSurge"`
+	MaxUnavailable          string `json:"maxUnavailable"`
+	MinReadySeconds         string `json:"minReadySeconds"`
+	RevisionHistoryLimit    string `json:"revisionHistoryLimit"`
+	ProgressDeadlineSeconds string `json:"progressDeadlineSeconds"`
+}
+
+// The security settings of a deployment - user and group IDs, fsGroup and seccompProfile are set on the pod, the rest on every container
+type SecurityContextStruct struct {
+	// "restricted" fills in the settings the restricted Pod Security Standard requires - runAsNonRoot, no privilege escalation,
+	// all capabilities dropped and the RuntimeDefault seccomp profile. Other fields in the request are applied on top of it
+	Preset                   string   `json:"preset"`
+	RunAsUser                string   `json:"runAsUser"`
+	RunAsGroup               string   `json:"runAsGroup"`
+	RunAsNonRoot             *bool    `json:"runAsNonRoot"`
+	FSGroup                  string   `json:"fsGroup"`
+	ReadOnlyRootFilesystem   *bool    `json:"readOnlyRootFilesystem"`
+	AllowPrivilegeEscalation *bool    `json:"allowPrivilegeEscalation"`
+	DropCapabilities         []string `json:"dropCapabilities"`
+	// One of RuntimeDefault, Localhost or Unconfined - Localhost also needs seccompLocalhostProfile
+	SeccompProfile          string `json:"seccompProfile"`
+	SeccompLocalhostProfile string `json:"seccompLocalhostProfile"`
+}
+
+type ScaleDeploymentStruct struct {
+	// Either an absolute replica count, e.g. "3", or a change relative to the current count, e.g. "+2" or "-1"
+	Replicas string `json:"replicas"`
+}
+
+type RollbackDeploymentStruct struct {
+	// The revision to roll back to - if empty or "0", the deployment is rolled back to the previous revision
+	Revision string `json:"revision"`
+}
+
+type CloneDeploymentStruct struct {
+	// The name of the new deployment
+	DeploymentName string `json:"deploymentName"`
+	// Optional overrides - the app label, replica count and main container's image tag default to the source deployment's
+	DeploymentLabel   string `json:"deploymentLabel"`
+	ReplicaCount      string `json:"replicaCount"`
+	ContainerImageTag string `json:"containerImageTag"`
+}
+
+type InstantiateTemplateStruct struct {
+	// The value of each variable used in the template, e.g. {"name": "web", "tag": "v2"}
+	Variables map[string]string `json:"variables"`
 }
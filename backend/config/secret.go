@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Name of the Secret holding the registry credentials for a deployment using a private registry
+func ImagePullSecretName(deploymentName string) string {
+	return fmt.Sprintf("%s-image-pull-secret", deploymentName)
+}
+
+// Build the image pull Secret for a deployment using a private registry
+// Image pull secrets are required for private registries - we create a k8s secret to store the credentials and then reference it in ImagePullSecrets for the PodSpec
+// https://kubernetes.io/docs/tasks/configure-pod-container/pull-image-private-registry/#create-a-secret-by-providing-credentials-on-the-command-line
+func NewImagePullSecret(deploymentName string, auths map[string]RegistryAuth) (*apiv1.Secret, error) {
+	secretData, err := json.Marshal(RegistryConfig{Auths: auths})
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   ImagePullSecretName(deploymentName),
+			Labels: map[string]string{ManagedByLabel: ManagedBy},
+		},
+		Type: apiv1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{apiv1.DockerConfigJsonKey: secretData},
+	}, nil
+}
+
+// Get the registry credentials stored in an image pull Secret, keyed by registry server
+func ImagePullSecretAuths(secret *apiv1.Secret) (map[string]RegistryAuth, error) {
+	registryConfig := RegistryConfig{}
+	if err := json.Unmarshal(secret.Data[apiv1.DockerConfigJsonKey], &registryConfig); err != nil {
+		return nil, err
+	}
+	if registryConfig.Auths == nil {
+		registryConfig.Auths = map[string]RegistryAuth{}
+	}
+
+	return registryConfig.Auths, nil
+}
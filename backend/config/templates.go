@@ -0,0 +1,191 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Returned when a request targets a template that doesn't exist
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Returned when a template is created with the name of one that already exists
+var ErrTemplateExists = errors.New("template already exists")
+
+// A named, partially filled in deployment that can be created again and again
+type DeploymentTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// The fields of a CreateDeploymentStruct - any string value in it can hold {{variables}}, which are filled in when the template is instantiated
+	Deployment map[string]interface{} `json:"deployment"`
+	// The variables used in the deployment, set when the template is saved
+	Variables []string  `json:"variables"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Templates kept in memory and persisted to a JSON file, which is rewritten on every change
+type TemplateStore struct {
+	mu        sync.RWMutex
+	path      string
+	templates map[string]DeploymentTemplate
+}
+
+// The store loaded at startup with LoadTemplates
+var templateStore = &TemplateStore{templates: map[string]DeploymentTemplate{}}
+
+// Load the templates from the file passed with the --templates flag
+// A file that doesn't exist yet is treated as an empty store, and is created when the first template is saved
+func LoadTemplates(path string) error {
+	store := &TemplateStore{path: path, templates: map[string]DeploymentTemplate{}}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil && len(data) > 0 {
+		templates := []DeploymentTemplate{}
+		if err := json.Unmarshal(data, &templates); err != nil {
+			return fmt.Errorf("templates file %s can't be parsed: %w", path, err)
+		}
+		for _, template := range templates {
+			store.templates[template.Name] = template
+		}
+	}
+	templateStore = store
+
+	return nil
+}
+
+// Get the template store
+func Templates() *TemplateStore {
+	return templateStore
+}
+
+// List the templates, sorted by name
+func (s *TemplateStore) List() []DeploymentTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sorted()
+}
+
+// The templates sorted by name - the caller holds the lock
+func (s *TemplateStore) sorted() []DeploymentTemplate {
+	templates := []DeploymentTemplate{}
+	for _, template := range s.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	return templates
+}
+
+// Get a template by name
+func (s *TemplateStore) Get(name string) (DeploymentTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	template, ok := s.templates[name]
+	if !ok {
+		return DeploymentTemplate{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	return template, nil
+}
+
+// Save a new template
+func (s *TemplateStore) Create(template DeploymentTemplate) (DeploymentTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.templates[template.Name]; ok {
+		return DeploymentTemplate{}, fmt.Errorf("%w: %s", ErrTemplateExists, template.Name)
+	}
+	template.CreatedAt = time.Now().UTC()
+	template.UpdatedAt = template.CreatedAt
+
+	return template, s.put(template)
+}
+
+// Replace an existing template
+func (s *TemplateStore) Update(template DeploymentTemplate) (DeploymentTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.templates[template.Name]
+	if !ok {
+		return DeploymentTemplate{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, template.Name)
+	}
+	template.CreatedAt = existing.CreatedAt
+	template.UpdatedAt = time.Now().UTC()
+
+	return template, s.put(template)
+}
+
+// Delete a template by name
+func (s *TemplateStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.templates[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	delete(s.templates, name)
+	if err := s.save(); err != nil {
+		// Keep the store in line with the file
+		s.templates[name] = existing
+		return err
+	}
+
+	return nil
+}
+
+// Add or replace a template and persist it - the caller holds the lock
+func (s *TemplateStore) put(template DeploymentTemplate) error {
+	existing, ok := s.templates[template.Name]
+	s.templates[template.Name] = template
+	if err := s.save(); err != nil {
+		// Keep the store in line with the file
+		if ok {
+			s.templates[template.Name] = existing
+		} else {
+			delete(s.templates, template.Name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Write the templates to the file - the caller holds the lock
+// The file is written next to the old one and renamed over it, so a failed write doesn't lose the templates already saved
+func (s *TemplateStore) save() error {
+	if s.path == "" {
+		return errors.New("no templates file is set")
+	}
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), s.path)
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// The kinds that can be applied, and the resource each one is served under
+var applyableKinds = map[schema.GroupVersionKind]schema.GroupVersionResource{
+	{Group: "apps", Version: "v1", Kind: "Deployment"}: {Group: "apps", Version: "v1", Resource: "deployments"},
+	{Version: "v1", Kind: "Service"}:                   {Version: "v1", Resource: "services"},
+	{Version: "v1", Kind: "ConfigMap"}:                 {Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Kind: "Secret"}:                    {Version: "v1", Resource: "secrets"},
+}
+
+// The result of applying one object from a manifest
+type applyResult struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	// Either applied or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// The object as the API server stored it - the data of Secrets is left out
+	Object map[string]interface{} `json:"object,omitempty"`
+}
+
+// Apply a YAML or JSON manifest with server-side apply - the same as `kubectl apply --server-side`
+// The body can hold several objects, as YAML documents separated by "---", a stream of JSON objects or a List
+// Deployments, Services, ConfigMaps and Secrets are supported. Objects without a namespace are applied to the request's namespace
+// Every object is checked before anything is applied, then each one is applied in order and its result returned
+func ApplyManifests(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// Fields owned by another field manager, e.g. kubectl, can only be taken over with force
+	force := false
+	if c.Query("force") != "" {
+		if force, err = strconv.ParseBool(c.Query("force")); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "force must be true or false"})
+		}
+	}
+
+	objects, err := parseManifest(c.Body())
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(objects) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "The manifest doesn't contain any objects"})
+	}
+
+	results := []applyResult{}
+	failed := 0
+	for _, object := range objects {
+		if object.GetNamespace() == "" {
+			object.SetNamespace(namespace)
+		}
+		prepareApply(object)
+
+		result := applyResult{APIVersion: object.GetAPIVersion(), Kind: object.GetKind(), Namespace: object.GetNamespace(), Name: object.GetName()}
+		gvr := applyableKinds[object.GroupVersionKind()]
+		zap.L().Info(dryRunMessage(dryRun, "Applying "+object.GetKind()+" "+object.GetNamespace()+"/"+object.GetName()))
+		applied, err := cluster.Dynamic.Resource(gvr).Namespace(object.GetNamespace()).Apply(context.TODO(), object.GetName(), object, metav1.ApplyOptions{
+			FieldManager: config.ManagedBy,
+			Force:        force,
+			DryRun:       dryRun,
+		})
+		if err != nil {
+			zap.L().Error(err.Error())
+			result.Status = "failed"
+			result.Error = err.Error()
+			failed++
+		} else {
+			zap.L().Info(dryRunMessage(dryRun, "Applied "+object.GetKind()+" "+object.GetNamespace()+"/"+object.GetName()))
+			result.Status = "applied"
+			result.Object = applied.Object
+			if object.GetKind() == "Secret" {
+				unstructured.RemoveNestedField(result.Object, "data")
+				unstructured.RemoveNestedField(result.Object, "stringData")
+			}
+		}
+		results = append(results, result)
+	}
+
+	message := fmt.Sprintf("Applied %d of %d objects", len(objects)-failed, len(objects))
+	// Some objects may have been applied before another failed - 207 tells the client to check each result
+	status := fiber.StatusOK
+	if failed > 0 {
+		status = fiber.StatusMultiStatus
+	}
+
+	return c.Status(status).JSON(fiber.Map{"message": dryRunMessage(dryRun, message), "results": results})
+}
+
+// Prepare an object from a manifest to be applied
+func prepareApply(object *unstructured.Unstructured) {
+	// Mark the object as managed by this app, unless the manifest already says what manages it
+	labels := object.GetLabels()
+	if _, ok := labels[config.ManagedByLabel]; !ok {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[config.ManagedByLabel] = config.ManagedBy
+		object.SetLabels(labels)
+	}
+	// Server-side apply rejects objects that set managedFields
+	object.SetManagedFields(nil)
+}
+
+// Parse the objects in a YAML or JSON manifest, and check each one can be applied
+func parseManifest(body []byte) ([]*unstructured.Unstructured, error) {
+	objects := []*unstructured.Unstructured{}
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096)
+	for document := 0; ; document++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, newFieldError(fmt.Sprintf("documents[%d]", document), "can't be parsed: %s", err.Error())
+		}
+		// Empty YAML documents, e.g. a trailing "---", are skipped
+		if len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null" {
+			continue
+		}
+
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(raw); err != nil {
+			return nil, newFieldError(fmt.Sprintf("documents[%d]", document), "isn't a Kubernetes object: %s", err.Error())
+		}
+		items := []*unstructured.Unstructured{object}
+		if object.IsList() {
+			list, err := object.ToList()
+			if err != nil {
+				return nil, newFieldError(fmt.Sprintf("documents[%d]", document), "isn't a valid List: %s", err.Error())
+			}
+			items = []*unstructured.Unstructured{}
+			for i := range list.Items {
+				items = append(items, &list.Items[i])
+			}
+		}
+
+		for _, item := range items {
+			field := fmt.Sprintf("objects[%d]", len(objects))
+			if _, ok := applyableKinds[item.GroupVersionKind()]; !ok {
+				return nil, newFieldError(field, "%s %s can't be applied - only apps/v1 Deployments and v1 Services, ConfigMaps and Secrets are supported", item.GetAPIVersion(), item.GetKind())
+			}
+			if item.GetName() == "" {
+				return nil, newFieldError(field+".metadata.name", "is required")
+			}
+			if item.GetGenerateName() != "" {
+				return nil, newFieldError(field+".metadata.generateName", "can't be used with apply")
+			}
+			objects = append(objects, item)
+		}
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Create a copy of a deployment under a new name
+// The pod template is copied, and the app and owner labels that make up the selector are rewritten so the copy manages its own pods
+// If the source deployment has an image pull secret, it's copied for the new deployment as well
+func CloneDeployment(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var cloneDeploymentStruct = config.CloneDeploymentStruct{}
+	// Parse the request body into the cloneDeploymentStruct struct
+	if err := c.BodyParser(&cloneDeploymentStruct); err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	source, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	clone, err := buildClone(source, cloneDeploymentStruct)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// The image pull secret is looked up in the informer cache - a deployment using public registries doesn't have one
+	sourceSecret, err := cluster.SecretLister.Secrets(namespace).Get(config.ImagePullSecretName(deploymentName))
+	if err != nil && !apierrors.IsNotFound(err) {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if sourceSecret != nil {
+		secret := &apiv1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   config.ImagePullSecretName(clone.GetName()),
+				Labels: map[string]string{config.ManagedByLabel: config.ManagedBy},
+			},
+			Type: sourceSecret.Type,
+			Data: map[string][]byte{},
+		}
+		for key, value := range sourceSecret.Data {
+			secret.Data[key] = append([]byte{}, value...)
+		}
+		if _, err := cluster.Clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{DryRun: dryRun}); err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		zap.L().Info(dryRunMessage(dryRun, "Copied secret "+sourceSecret.GetName()+" to "+secret.GetName()))
+	}
+
+	zap.L().Info(dryRunMessage(dryRun, "Cloning deployment "+deploymentName+" to "+clone.GetName()))
+	result, err := cluster.Clientset.AppsV1().Deployments(namespace).Create(context.TODO(), clone, metav1.CreateOptions{DryRun: dryRun})
+	if err != nil {
+		zap.L().Error(err.Error())
+		// The secret is created first so the copy's pods can pull their image straight away - don't leave it behind for a copy that wasn't made
+		if sourceSecret != nil && len(dryRun) == 0 {
+			deleteOrphanedSecret(cluster.Clientset.CoreV1().Secrets(namespace), config.ImagePullSecretName(clone.GetName()))
+		}
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	zap.L().Info(dryRunMessage(dryRun, "Cloned deployment "+deploymentName+" to "+result.GetName()))
+	if len(dryRun) == 0 {
+		waitForCache(func() bool {
+			_, err := cluster.DeploymentLister.Deployments(namespace).Get(result.GetName())
+			return err == nil
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": dryRunMessage(dryRun, "Cloned deployment "+deploymentName+" to "+result.GetName()), "deployment": result})
+}
+
+// Rewrite the label selectors in a pod spec that select pods by label value - rewrites maps a label key to its old and new value
+// Only selectors using the old value are changed, so ones selecting other apps' pods are left as is
+func rewritePodSelectors(podSpec *apiv1.PodSpec, rewrites map[string][2]string) {
+	for i := range podSpec.TopologySpreadConstraints {
+		rewriteLabelSelector(podSpec.TopologySpreadConstraints[i].LabelSelector, rewrites)
+	}
+	if podSpec.Affinity == nil {
+		return
+	}
+	rewriteTerms := func(required []apiv1.PodAffinityTerm, preferred []apiv1.WeightedPodAffinityTerm) {
+		for i := range required {
+			rewriteLabelSelector(required[i].LabelSelector, rewrites)
+		}
+		for i := range preferred {
+			rewriteLabelSelector(preferred[i].PodAffinityTerm.LabelSelector, rewrites)
+		}
+	}
+	if podSpec.Affinity.PodAffinity != nil {
+		rewriteTerms(podSpec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, podSpec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution)
+	}
+	if podSpec.Affinity.PodAntiAffinity != nil {
+		rewriteTerms(podSpec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution)
+	}
+}
+
+// Rewrite the label values of a selector, in both matchLabels and the values of matchExpressions
+func rewriteLabelSelector(selector *metav1.LabelSelector, rewrites map[string][2]string) {
+	if selector == nil {
+		return
+	}
+	for key, rewrite := range rewrites {
+		if value, ok := selector.MatchLabels[key]; ok && value == rewrite[0] {
+			selector.MatchLabels[key] = rewrite[1]
+		}
+		for i := range selector.MatchExpressions {
+			if selector.MatchExpressions[i].Key != key {
+				continue
+			}
+			for j, value := range selector.MatchExpressions[i].Values {
+				if value == rewrite[0] {
+					selector.MatchExpressions[i].Values[j] = rewrite[1]
+				}
+			}
+		}
+	}
+}
+
+// Build the copy of a deployment described by a clone request
+func buildClone(source *appsv1.Deployment, cloneDeploymentStruct config.CloneDeploymentStruct) (*appsv1.Deployment, error) {
+	if cloneDeploymentStruct.DeploymentName == "" {
+		return nil, newFieldError("deploymentName", "is required")
+	}
+	if errs := validation.IsDNS1123Subdomain(cloneDeploymentStruct.DeploymentName); len(errs) > 0 {
+		return nil, newFieldError("deploymentName", "%q is invalid: %s", cloneDeploymentStruct.DeploymentName, strings.Join(errs, ", "))
+	}
+	// Without the owner label in the selector, the copy's pods would also match the source deployment's selector
+	if _, ok := source.Spec.Selector.MatchLabels["owner"]; !ok {
+		return nil, apierrors.NewBadRequest("Deployment " + source.GetName() + " doesn't select its pods by the owner label, so a copy of it would overlap with it")
+	}
+
+	clone := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cloneDeploymentStruct.DeploymentName,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	for key, value := range source.Labels {
+		clone.Labels[key] = value
+	}
+	for key, value := range source.Annotations {
+		clone.Annotations[key] = value
+	}
+	for _, annotation := range serverAnnotations {
+		delete(clone.Annotations, annotation)
+	}
+	clone.Labels[config.ManagedByLabel] = config.ManagedBy
+	// The copy starts rolling out straight away, even if the source is paused
+	clone.Spec.Paused = false
+
+	// Rewrite the labels that make up the selector, on both the selector and the pod template
+	templateLabels := clone.Spec.Template.Labels
+	if templateLabels == nil {
+		templateLabels = map[string]string{}
+	}
+	delete(templateLabels, appsv1.DefaultDeploymentUniqueLabelKey)
+	clone.Spec.Selector.MatchLabels["owner"] = clone.GetName()
+	templateLabels["owner"] = clone.GetName()
+	if cloneDeploymentStruct.DeploymentLabel != "" {
+		if err := validateLabel("deploymentLabel", "app", cloneDeploymentStruct.DeploymentLabel); err != nil {
+			return nil, err
+		}
+		clone.Spec.Selector.MatchLabels["app"] = cloneDeploymentStruct.DeploymentLabel
+		templateLabels["app"] = cloneDeploymentStruct.DeploymentLabel
+	}
+	templateLabels[config.ManagedByLabel] = config.ManagedBy
+	clone.Spec.Template.Labels = templateLabels
+	// Topology spread constraints and pod (anti-)affinity select the source's pods by the same labels, so they're pointed at the copy's pods
+	rewrites := map[string][2]string{"owner": {source.Spec.Selector.MatchLabels["owner"], clone.GetName()}}
+	if cloneDeploymentStruct.DeploymentLabel != "" {
+		rewrites["app"] = [2]string{source.Spec.Selector.MatchLabels["app"], cloneDeploymentStruct.DeploymentLabel}
+	}
+	rewritePodSelectors(&clone.Spec.Template.Spec, rewrites)
+
+	// Point the pod template at the copy of the image pull secret
+	for i, secret := range clone.Spec.Template.Spec.ImagePullSecrets {
+		if secret.Name == config.ImagePullSecretName(source.GetName()) {
+			clone.Spec.Template.Spec.ImagePullSecrets[i].Name = config.ImagePullSecretName(clone.GetName())
+		}
+	}
+
+	if cloneDeploymentStruct.ReplicaCount != "" {
+		replicaCount, err := parseInt32Field("replicaCount", cloneDeploymentStruct.ReplicaCount, 0)
+		if err != nil {
+			return nil, err
+		}
+		clone.Spec.Replicas = config.Int32Ptr(replicaCount)
+	}
+	// The image tag override applies to the main container, the same as the top level fields of an update
+	if cloneDeploymentStruct.ContainerImageTag != "" {
+		if len(clone.Spec.Template.Spec.Containers) == 0 {
+			return nil, newFieldError("containerImageTag", "deployment %s has no containers", source.GetName())
+		}
+		container := &clone.Spec.Template.Spec.Containers[0]
+		// The source's tag and digest are both replaced by the new tag
+		registryServer, imageName, _, _ := splitImage(container.Image)
+		container.Image = joinImage(registryServer, imageName, cloneDeploymentStruct.ContainerImageTag, "")
+	}
+
+	return clone, nil
+}
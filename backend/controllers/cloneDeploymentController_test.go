@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"testing"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildCloneRewritesPodSelectors(t *testing.T) {
+	selector := func() *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web", "owner": "web"}}
+	}
+	source := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: selector(),
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", "owner": "web"}},
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{{Name: "web", Image: "nginx"}},
+					TopologySpreadConstraints: []apiv1.TopologySpreadConstraint{
+						{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", LabelSelector: selector()},
+					},
+					Affinity: &apiv1.Affinity{
+						PodAntiAffinity: &apiv1.PodAntiAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: []apiv1.PodAffinityTerm{
+								{TopologyKey: "kubernetes.io/hostname", LabelSelector: &metav1.LabelSelector{
+									MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "owner", Operator: metav1.LabelSelectorOpIn, Values: []string{"web"}}},
+								}},
+							},
+						},
+						PodAffinity: &apiv1.PodAffinity{
+							PreferredDuringSchedulingIgnoredDuringExecution: []apiv1.WeightedPodAffinityTerm{
+								// Selects another app's pods, so it's left as is
+								{Weight: 1, PodAffinityTerm: apiv1.PodAffinityTerm{TopologyKey: "kubernetes.io/hostname", LabelSelector: &metav1.LabelSelector{
+									MatchLabels: map[string]string{"app": "cache", "owner": "cache"},
+								}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	clone, err := buildClone(source, config.CloneDeploymentStruct{DeploymentName: "web-copy", DeploymentLabel: "web-copy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec := clone.Spec.Template.Spec
+	if got := podSpec.TopologySpreadConstraints[0].LabelSelector.MatchLabels; got["owner"] != "web-copy" || got["app"] != "web-copy" {
+		t.Errorf("topology spread constraint selector = %v, want owner and app web-copy", got)
+	}
+	if got := podSpec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].LabelSelector.MatchExpressions[0].Values; got[0] != "web-copy" {
+		t.Errorf("pod anti-affinity selector values = %v, want [web-copy]", got)
+	}
+	if got := podSpec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.LabelSelector.MatchLabels; got["owner"] != "cache" || got["app"] != "cache" {
+		t.Errorf("pod affinity selector for another app = %v, want it unchanged", got)
+	}
+	// The source is left as is
+	if got := source.Spec.Template.Spec.TopologySpreadConstraints[0].LabelSelector.MatchLabels["owner"]; got != "web" {
+		t.Errorf("source topology spread constraint owner = %q, want web", got)
+	}
+}
@@ -2,134 +2,107 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"strconv"
 
 	config "github.com/Ajsalemo/kubernetes-client-application/config"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
-	apiv1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 func CreateDeployment(c *fiber.Ctx) error {
-	var secretData []byte
-	clientset, err := config.KubeConfig()
+	cluster, err := getCluster(c)
 	if err != nil {
 		zap.L().Error(err.Error())
-		panic(err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	var createDeploymentStruct = config.CreateDeploymentStruct{}
-	deploymentsClient := clientset.AppsV1().Deployments(apiv1.NamespaceDefault)
 	// Parse the request body into the createDeploymentStruct struct
 	if err := c.BodyParser(&createDeploymentStruct); err != nil {
 		zap.L().Error(err.Error())
-		return err
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-	// If the deployment is using a private registry, create a secret for the registry
-	// Image pull secrets are required for private registries - we create a k8s secret to store the credentials and then reference it in ImagePullSecrets for the PodSpec
-	// https://kubernetes.io/docs/tasks/configure-pod-container/pull-image-private-registry/#create-a-secret-by-providing-credentials-on-the-command-line
-	if createDeploymentStruct.RegistryType == "private" {
-		conf := config.RegistryConfig{
-			Auths: map[string]config.RegistryAuth{
-				createDeploymentStruct.ContainerRegistryServer: {
-					Username: createDeploymentStruct.RegistryUsername,
-					Password: createDeploymentStruct.RegistryPassword,
-				},
-			},
-		}
-		secretData, _ = json.Marshal(conf)
-		// Create the secret object
-		secret := &apiv1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: fmt.Sprintf("%s-image-pull-secret", createDeploymentStruct.DeploymentName),
-			},
-			Type: "kubernetes.io/dockerconfigjson",
-			Data: map[string][]byte{".dockerconfigjson": []byte(secretData)},
-		}
 
-		// Create the Secret used for image pulls with private registries
-		_, secretErr := clientset.CoreV1().Secrets(apiv1.NamespaceDefault).Create(context.TODO(), secret, metav1.CreateOptions{})
-		if secretErr != nil {
-			zap.L().Error(secretErr.Error())
-			return c.Status(500).JSON(fiber.Map{"error": secretErr.Error()})
-		}
+	return respondCreateDeployment(c, cluster, namespace, createDeploymentStruct, dryRun)
+}
 
-		zap.L().Info("Created secret " + secret.ObjectMeta.Name)
-	}
-	// Convert containerPort from a string to int32
-	containerPort, err := strconv.ParseInt(createDeploymentStruct.ContainerPort, 10, 32)
+// Create the deployment described by a CreateDeploymentStruct and write the response
+// This is shared by the create endpoint and instantiating a template
+func respondCreateDeployment(c *fiber.Ctx, cluster *config.Cluster, namespace string, createDeploymentStruct config.CreateDeploymentStruct, dryRun []string) error {
+	result, err := createDeployment(cluster, namespace, createDeploymentStruct, dryRun)
 	if err != nil {
 		zap.L().Error(err.Error())
-		return err
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
-	// Convert replicaCount from a string to int32
-	replicaCount, err := strconv.ParseInt(createDeploymentStruct.ReplicaCount, 10, 32)
-	if err != nil {
-		zap.L().Error(err.Error())
-		return err
+	// A dry run returns the deployment as the API server would have stored it, with defaults and admission webhook changes applied
+	if len(dryRun) > 0 {
+		return c.JSON(fiber.Map{"message": dryRunMessage(dryRun, "Created deployment "+result.GetObjectMeta().GetName()), "deployment": result})
 	}
+	waitForCache(func() bool {
+		_, err := cluster.DeploymentLister.Deployments(namespace).Get(result.GetObjectMeta().GetName())
+		return err == nil
+	})
 
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: createDeploymentStruct.DeploymentName,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: config.Int32Ptr(int32(replicaCount)),
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app":   createDeploymentStruct.DeploymentLabel,
-					"owner": createDeploymentStruct.DeploymentName,
-				},
-			},
-			Template: apiv1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app":   createDeploymentStruct.DeploymentLabel,
-						"owner": createDeploymentStruct.DeploymentName,
-					},
-				},
-				Spec: apiv1.PodSpec{
-					Containers: []apiv1.Container{
-						{
-							Name:  createDeploymentStruct.ContainerName,
-							Image: createDeploymentStruct.ContainerRegistryServer + "/" + createDeploymentStruct.ContainerImageName + ":" + createDeploymentStruct.ContainerImageTag,
-							Ports: []apiv1.ContainerPort{
-								{
-									Name:          "http",
-									Protocol:      apiv1.ProtocolTCP,
-									ContainerPort: int32(containerPort),
-								},
-							},
-							Resources: apiv1.ResourceRequirements{
-								Limits: apiv1.ResourceList{
-									apiv1.ResourceCPU:    resource.MustParse(createDeploymentStruct.CPU),
-									apiv1.ResourceMemory: resource.MustParse(createDeploymentStruct.Memory),
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+	return c.JSON(fiber.Map{"message": "Created deployment " + result.GetObjectMeta().GetName()})
+}
+
+// Create the deployment described by a CreateDeploymentStruct, along with its image pull secret if it uses a private registry
+func createDeployment(cluster *config.Cluster, namespace string, createDeploymentStruct config.CreateDeploymentStruct, dryRun []string) (*appsv1.Deployment, error) {
+	// Build and validate the deployment before anything is created, so invalid input doesn't leave an image pull secret behind
+	deployment, err := buildDeployment(createDeploymentStruct)
+	if err != nil {
+		return nil, err
 	}
-	// If the deployment is using a private registry, add the image pull secret to the deployment
-	// Otherwise, this isn't added as this is an optional field and will be assumed a public registry is used
-	if createDeploymentStruct.RegistryType == "private" {
-		deployment.Spec.Template.Spec.ImagePullSecrets = []apiv1.LocalObjectReference{{Name: fmt.Sprintf("%s-image-pull-secret", createDeploymentStruct.DeploymentName)}}
+	// If any container is using a private registry, create a secret for the registry
+	// Credentials for every private registry the containers use are stored in the one secret
+	auths, err := registryAuths(createDeploymentStruct)
+	if err != nil {
+		return nil, err
+	}
+	if len(auths) > 0 {
+		secret, err := config.NewImagePullSecret(createDeploymentStruct.DeploymentName, auths)
+		if err != nil {
+			return nil, err
+		}
+
+		// Create the Secret used for image pulls with private registries
+		if _, err := cluster.Clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{DryRun: dryRun}); err != nil {
+			return nil, err
+		}
+
+		zap.L().Info(dryRunMessage(dryRun, "Created secret "+secret.ObjectMeta.Name))
 	}
 
 	// Create Deployment
 	zap.L().Info("Creating deployment " + createDeploymentStruct.DeploymentName)
-	result, err := deploymentsClient.Create(context.TODO(), deployment, metav1.CreateOptions{})
+	result, err := cluster.Clientset.AppsV1().Deployments(namespace).Create(context.TODO(), deployment, metav1.CreateOptions{DryRun: dryRun})
 	if err != nil {
-		zap.L().Error(err.Error())
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		// The secret is created first so the pods can pull their image straight away - don't leave it behind for a deployment that wasn't created
+		if len(auths) > 0 && len(dryRun) == 0 {
+			deleteOrphanedSecret(cluster.Clientset.CoreV1().Secrets(namespace), config.ImagePullSecretName(createDeploymentStruct.DeploymentName))
+		}
+		return nil, err
 	}
-	zap.L().Info("Created deployment " + result.GetObjectMeta().GetName())
-	return c.JSON(fiber.Map{"message": "Created deployment " + result.GetObjectMeta().GetName()})
+	zap.L().Info(dryRunMessage(dryRun, "Created deployment "+result.GetObjectMeta().GetName()))
+
+	return result, nil
+}
+
+// Delete an image pull secret that was created for a deployment that failed to be created
+// A failure is only logged, since the error returned to the client is the one from creating the deployment
+func deleteOrphanedSecret(secretClient corev1client.SecretInterface, secretName string) {
+	if err := secretClient.Delete(context.TODO(), secretName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		zap.L().Error("Failed to delete secret " + secretName + ": " + err.Error())
+		return
+	}
+	zap.L().Info("Deleted secret " + secretName + " created for a deployment that failed to be created")
 }
@@ -8,16 +8,20 @@ import (
 	config "github.com/Ajsalemo/kubernetes-client-application/config"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func DeleteDeployment(c *fiber.Ctx) error {
-	clientset, err := config.KubeConfig()
+	cluster, err := getCluster(c)
 	if err != nil {
 		zap.L().Error(err.Error())
-		panic(err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
 
 	// Check if the parameter is empty - if so, return a 400 for bad request
 	if c.Params("deployment") == "" {
@@ -27,24 +31,45 @@ func DeleteDeployment(c *fiber.Ctx) error {
 	deploymentName := c.Params("deployment")
 	zap.L().Info("User provided deployment name: " + deploymentName)
 
-	deploymentsClient := clientset.AppsV1().Deployments(apiv1.NamespaceDefault)
-	secretClient := clientset.CoreV1().Secrets(apiv1.NamespaceDefault)
+	deploymentsClient := cluster.Clientset.AppsV1().Deployments(namespace)
+	secretClient := cluster.Clientset.CoreV1().Secrets(namespace)
 	deletePolicy := metav1.DeletePropagationForeground
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// A dry run returns the deployment as the API server would leave it, and there's nothing to wait for
+	if len(dryRun) > 0 {
+		if _, err := cluster.SecretLister.Secrets(namespace).Get(config.ImagePullSecretName(deploymentName)); err == nil {
+			if err := secretClient.Delete(context.TODO(), config.ImagePullSecretName(deploymentName), metav1.DeleteOptions{DryRun: dryRun}); err != nil {
+				zap.L().Error(err.Error())
+				return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+		result, err := dryRunDelete(cluster.Clientset.AppsV1().RESTClient(), namespace, "deployments", deploymentName, metav1.DeleteOptions{PropagationPolicy: &deletePolicy})
+		if err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		zap.L().Info(dryRunMessage(dryRun, "Deleted deployment "+deploymentName))
+		return c.JSON(fiber.Map{"message": dryRunMessage(dryRun, "Deleted deployment "+deploymentName), "deployment": result})
+	}
 
 	// If a secret exists for the deployment, delete it - this infers that the deployment is using a private registry
-	getSecret, err := secretClient.Get(context.TODO(), fmt.Sprintf("%s-image-pull-secret", deploymentName), metav1.GetOptions{})
+	// The secret is looked up in the informer cache
+	getSecret, err := cluster.SecretLister.Secrets(namespace).Get(config.ImagePullSecretName(deploymentName))
 	// If error is `nil`, the secret exists and we should delete it
 	if err == nil {
 		zap.L().Info("ImagePullSecrets: " + getSecret.GetName() + " found for deployment: " + deploymentName)
 		zap.L().Info("Deleting ImagePullSecrets: " + getSecret.GetName())
-		if err := secretClient.Delete(context.TODO(), fmt.Sprintf("%s-image-pull-secret", deploymentName), metav1.DeleteOptions{}); err != nil {
+		if err := secretClient.Delete(context.TODO(), config.ImagePullSecretName(deploymentName), metav1.DeleteOptions{}); err != nil {
 			zap.L().Error(err.Error())
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
 		zap.L().Info("Deleted ImagePullSecrets: " + getSecret.GetName() + " for deployment: " + deploymentName)
 	} else {
 		// If the error is not `nil`, the secret does not exist and this will infer a public registry was used
-		if err.Error() == "secrets \""+deploymentName+"-image-pull-secret\" not found" {
+		if apierrors.IsNotFound(err) {
 			zap.L().Info("No ImagePullSecrets found for deployment: " + deploymentName)
 			// Otherwise, return a 500 for any other error since this should indicate an actual error has occurred
 		} else {
@@ -69,39 +94,38 @@ func DeleteDeployment(c *fiber.Ctx) error {
 		select {
 		case <-ticker.C:
 			zap.L().Info("Polling to check if deployment: " + deploymentName + " is deleted")
-			// Create a Secret client to list secrets
-			secretListOptions := metav1.ListOptions{
-				FieldSelector: fmt.Sprintf("metadata.name=%s", fmt.Sprintf("%s-image-pull-secret", deploymentName)),
-			}
-
-			listSecrets, err := secretClient.List(context.TODO(), metav1.ListOptions{FieldSelector: secretListOptions.FieldSelector})
-			if err != nil {
+			// Poll the informer caches rather than the API server - list and get requests are served from the caches,
+			// so the deletion is only visible to the client once the caches have observed it
+			remainingSecrets := []*apiv1.Secret{}
+			secret, err := cluster.SecretLister.Secrets(namespace).Get(config.ImagePullSecretName(deploymentName))
+			if err == nil {
+				remainingSecrets = append(remainingSecrets, secret)
+			} else if !apierrors.IsNotFound(err) {
 				zap.L().Error(err.Error())
 				return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 			}
-			zap.L().Info("Secrets found: " + fmt.Sprint(len(listSecrets.Items)))
-			// If the length of the listSecrets is 0, log that no secrets were found
-			if (len(listSecrets.Items)) == 0 {
-				zap.L().Info("No ImagePullSecrets found for deployment: " + deploymentName + " - Number of ImagePullSecrets: " + fmt.Sprint(len(listSecrets.Items)))
+			zap.L().Info("Secrets found: " + fmt.Sprint(len(remainingSecrets)))
+			// If the length of the remainingSecrets is 0, log that no secrets were found
+			if (len(remainingSecrets)) == 0 {
+				zap.L().Info("No ImagePullSecrets found for deployment: " + deploymentName + " - Number of ImagePullSecrets: " + fmt.Sprint(len(remainingSecrets)))
 			} else {
-				zap.L().Info("ImagePullSecrets found for deployment: " + deploymentName + " - Number of ImagePullSecrets: " + fmt.Sprint(len(listSecrets.Items)))
+				zap.L().Info("ImagePullSecrets found for deployment: " + deploymentName + " - Number of ImagePullSecrets: " + fmt.Sprint(len(remainingSecrets)))
 				// Log the Secret names
-				for _, s := range listSecrets.Items {
+				for _, s := range remainingSecrets {
 					zap.L().Info(" * " + s.GetName())
 				}
 			}
-			// Create a deployment client to list deployments
-			deploymentsClient := clientset.AppsV1().Deployments(apiv1.NamespaceDefault)
-			deploymentListOptions := metav1.ListOptions{
-				FieldSelector: fmt.Sprintf("metadata.name=%s", deploymentName),
-			}
-			getDeployment, err := deploymentsClient.List(context.TODO(), metav1.ListOptions{FieldSelector: deploymentListOptions.FieldSelector})
-			if err != nil {
+			// Check the deployment cache for the deployment
+			getDeployment := []*appsv1.Deployment{}
+			deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+			if err == nil {
+				getDeployment = append(getDeployment, deployment)
+			} else if !apierrors.IsNotFound(err) {
 				zap.L().Error(err.Error())
 				return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 			}
 			// Log the deployment names
-			for _, d := range getDeployment.Items {
+			for _, d := range getDeployment {
 				zap.L().Info(" * " + d.GetName())
 			}
 
@@ -117,11 +141,11 @@ func DeleteDeployment(c *fiber.Ctx) error {
 			// This polls at .5 intervals. If the deployment is not found, this will indicate it's been deleted
 			// Since k8s GC may take some time to delete a deployment
 			// Secrets deletion is normally faster - we want both the deployment and associated image pull secret to be deleted
-			if len(getDeployment.Items) == 0 && len(listSecrets.Items) == 0 {
+			if len(getDeployment) == 0 && len(remainingSecrets) == 0 {
 				zap.L().Info("Deletion took " + elapsed.String())
-				zap.L().Info("Deployment: " + deploymentName + " is not found, Deployment has been deleted. Deployment items is " + fmt.Sprint(len(getDeployment.Items)))
-				if getSecret.GetName() != "" {
-					zap.L().Info("Secret: " + getSecret.GetName() + " is not found, Secret has been deleted. Secret items is " + fmt.Sprint(len(listSecrets.Items)))
+				zap.L().Info("Deployment: " + deploymentName + " is not found, Deployment has been deleted. Deployment items is " + fmt.Sprint(len(getDeployment)))
+				if getSecret != nil {
+					zap.L().Info("Secret: " + getSecret.GetName() + " is not found, Secret has been deleted. Secret items is " + fmt.Sprint(len(remainingSecrets)))
 					zap.L().Info("Deleted secret " + getSecret.GetName())
 				}
 				zap.L().Info("Deleted deployment " + deploymentName)
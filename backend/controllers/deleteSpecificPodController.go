@@ -4,20 +4,21 @@ import (
 	"context"
 	"time"
 
-	config "github.com/Ajsalemo/kubernetes-client-application/config"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
-	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Delete a specific pod
 func DeleteSpecificPod(c *fiber.Ctx) error {
-	clientset, err := config.KubeConfig()
+	cluster, err := getCluster(c)
 	if err != nil {
 		zap.L().Error(err.Error())
-		panic(err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
 	// Check if the parameters are empty - if so, return a 400 for bad request
 	if c.Params("pod") == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Pod name is required"})
@@ -27,7 +28,22 @@ func DeleteSpecificPod(c *fiber.Ctx) error {
 
 	zap.L().Info("User provided pod name: " + podName)
 
-	podsClient := clientset.CoreV1().Pods(apiv1.NamespaceDefault)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// A dry run returns the pod as the API server would leave it, and there's nothing to wait for
+	if len(dryRun) > 0 {
+		result, err := dryRunDelete(cluster.Clientset.CoreV1().RESTClient(), namespace, "pods", podName, metav1.DeleteOptions{})
+		if err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		zap.L().Info(dryRunMessage(dryRun, "Deleted pod "+podName))
+		return c.JSON(fiber.Map{"pods": dryRunMessage(dryRun, "Deleted pod "+podName), "pod": result})
+	}
+
+	podsClient := cluster.Clientset.CoreV1().Pods(namespace)
 	podDeleteErr := podsClient.Delete(context.TODO(), podName, metav1.DeleteOptions{})
 
 	if podDeleteErr != nil {
@@ -45,9 +61,11 @@ func DeleteSpecificPod(c *fiber.Ctx) error {
 		case <-ticker.C:
 			zap.L().Info("Polling to check if pod: " + podName + " is deleted")
 
-			_, err := podsClient.Get(context.TODO(), podName, metav1.GetOptions{})
+			// Poll the informer cache rather than the API server - pods are listed from the cache,
+			// so the deletion is only visible to the client once the cache has observed it
+			_, err := cluster.PodLister.Pods(namespace).Get(podName)
 			if err != nil {
-				if err.Error() == "pods \""+podName+"\" not found" {
+				if apierrors.IsNotFound(err) {
 					zap.L().Info("Pod: " + podName + " has been deleted")
 					return c.JSON(fiber.Map{"pods": "Deleted pod " + podName})
 				} else {
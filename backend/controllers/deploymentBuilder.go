@@ -0,0 +1,520 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxSurge and maxUnavailable are either a whole number of pods or a percentage
+var intOrPercentRegex = regexp.MustCompile(`^(\d+)%?$`)
+
+// Build the deployment described by a create request
+// Everything is validated here, before anything is submitted to the cluster - invalid fields are returned as a fieldError
+func buildDeployment(createDeploymentStruct config.CreateDeploymentStruct) (*appsv1.Deployment, error) {
+	// Convert replicaCount from a string to int32
+	replicaCount, err := parseInt32Field("replicaCount", createDeploymentStruct.ReplicaCount, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: createDeploymentStruct.DeploymentName,
+			// The managed-by label marks the deployment, and its pods, as created by this app
+			Labels: map[string]string{config.ManagedByLabel: config.ManagedBy},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: config.Int32Ptr(replicaCount),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":   createDeploymentStruct.DeploymentLabel,
+					"owner": createDeploymentStruct.DeploymentName,
+				},
+			},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app":                 createDeploymentStruct.DeploymentLabel,
+						"owner":               createDeploymentStruct.DeploymentName,
+						config.ManagedByLabel: config.ManagedBy,
+					},
+				},
+			},
+		},
+	}
+
+	if err := applyMetadata(deployment, createDeploymentStruct); err != nil {
+		return nil, err
+	}
+	if err := applyContainers(&deployment.Spec.Template.Spec, createDeploymentStruct); err != nil {
+		return nil, err
+	}
+	if err := applyScheduling(deployment, createDeploymentStruct); err != nil {
+		return nil, err
+	}
+	if err := applySecurityContext(&deployment.Spec.Template.Spec, createDeploymentStruct.SecurityContext); err != nil {
+		return nil, err
+	}
+	// If any container is using a private registry, add the image pull secret to the deployment
+	// Otherwise, this isn't added as this is an optional field and will be assumed a public registry is used
+	auths, err := registryAuths(createDeploymentStruct)
+	if err != nil {
+		return nil, err
+	}
+	if len(auths) > 0 {
+		deployment.Spec.Template.Spec.ImagePullSecrets = []apiv1.LocalObjectReference{{Name: config.ImagePullSecretName(createDeploymentStruct.DeploymentName)}}
+	}
+
+	if err := applyRolloutStrategy(deployment, createDeploymentStruct); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// The containers of a create request with the field path each one is validated under
+// The main container from the top level fields comes first, if it's set, followed by the containers list
+func requestContainers(createDeploymentStruct config.CreateDeploymentStruct) ([]string, []config.ContainerStruct) {
+	fields := []string{}
+	containers := []config.ContainerStruct{}
+	if createDeploymentStruct.ContainerName != "" || createDeploymentStruct.ContainerImageName != "" {
+		fields = append(fields, "")
+		containers = append(containers, createDeploymentStruct.ContainerStruct)
+	}
+	for i, container := range createDeploymentStruct.Containers {
+		fields = append(fields, fmt.Sprintf("containers[%d].", i))
+		containers = append(containers, container)
+	}
+
+	return fields, containers
+}
+
+// Build the volumes, containers and init containers of the pod
+func applyContainers(podSpec *apiv1.PodSpec, createDeploymentStruct config.CreateDeploymentStruct) error {
+	// Volumes are validated first so the containers' volume mounts can be checked against them
+	if err := validateVolumes(createDeploymentStruct.Volumes); err != nil {
+		return err
+	}
+	podSpec.Volumes = createDeploymentStruct.Volumes
+
+	// Container names must be unique across both containers and init containers
+	names := map[string]bool{}
+
+	fields, containers := requestContainers(createDeploymentStruct)
+	if len(containers) == 0 {
+		return newFieldError("containers", "at least one container is required")
+	}
+	for i, containerStruct := range containers {
+		container, err := buildContainer(fields[i], containerStruct, names, podSpec.Volumes)
+		if err != nil {
+			return err
+		}
+		podSpec.Containers = append(podSpec.Containers, container)
+	}
+	for i, containerStruct := range createDeploymentStruct.InitContainers {
+		if err := validateInitContainerProbes(fmt.Sprintf("initContainers[%d].", i), containerStruct); err != nil {
+			return err
+		}
+		container, err := buildContainer(fmt.Sprintf("initContainers[%d].", i), containerStruct, names, podSpec.Volumes)
+		if err != nil {
+			return err
+		}
+		podSpec.InitContainers = append(podSpec.InitContainers, container)
+	}
+
+	return nil
+}
+
+// Record the name of a container - names are required, and must be unique across both containers and init containers
+func claimContainerName(field string, name string, names map[string]bool) error {
+	if name == "" {
+		return newFieldError(field, "is required")
+	}
+	if names[name] {
+		return newFieldError(field, "%q is used by more than one container", name)
+	}
+	names[name] = true
+
+	return nil
+}
+
+// Build a single container - field is the path of the container in the request, used to name invalid fields
+func buildContainer(field string, containerStruct config.ContainerStruct, names map[string]bool, volumes []apiv1.Volume) (apiv1.Container, error) {
+	if err := claimContainerName(field+"containerName", containerStruct.ContainerName, names); err != nil {
+		return apiv1.Container{}, err
+	}
+	if containerStruct.ContainerImageName == "" {
+		return apiv1.Container{}, newFieldError(field+"containerImageName", "is required")
+	}
+	if containerStruct.RegistryType != "" && containerStruct.RegistryType != "private" && containerStruct.RegistryType != "public" {
+		return apiv1.Container{}, newFieldError(field+"registryType", "must be either private or public, got %q", containerStruct.RegistryType)
+	}
+	if containerStruct.RegistryType == "private" && containerStruct.ContainerRegistryServer == "" {
+		return apiv1.Container{}, newFieldError(field+"containerRegistryServer", "is required for a private registry")
+	}
+
+	container := apiv1.Container{
+		Name:  containerStruct.ContainerName,
+		Image: joinImage(containerStruct.ContainerRegistryServer, containerStruct.ContainerImageName, containerStruct.ContainerImageTag, ""),
+	}
+	if len(containerStruct.Ports) > 0 {
+		if containerStruct.ContainerPort != "" {
+			return apiv1.Container{}, newFieldError(field+"containerPort", "can't be set together with ports")
+		}
+		ports, err := buildPorts(field, containerStruct.Ports)
+		if err != nil {
+			return apiv1.Container{}, err
+		}
+		container.Ports = ports
+	} else if containerStruct.ContainerPort != "" {
+		// Convert containerPort from a string to int32 - containers such as sidecars don't always expose a port
+		containerPort, err := parsePortField(field+"containerPort", containerStruct.ContainerPort)
+		if err != nil {
+			return apiv1.Container{}, err
+		}
+		container.Ports = []apiv1.ContainerPort{
+			{
+				Name:          "http",
+				Protocol:      apiv1.ProtocolTCP,
+				ContainerPort: containerPort,
+			},
+		}
+	}
+	if err := validateEnv(field, containerStruct.Env, containerStruct.EnvFrom); err != nil {
+		return apiv1.Container{}, err
+	}
+	container.Env = containerStruct.Env
+	container.EnvFrom = containerStruct.EnvFrom
+	if err := validateVolumeMounts(field, containerStruct.VolumeMounts, volumes); err != nil {
+		return apiv1.Container{}, err
+	}
+	container.VolumeMounts = containerStruct.VolumeMounts
+	if err := applyResources(field, &container, containerStruct); err != nil {
+		return apiv1.Container{}, err
+	}
+	if err := applyProbes(field, &container, containerStruct); err != nil {
+		return apiv1.Container{}, err
+	}
+
+	return container, nil
+}
+
+// Build the ports of a container - field is the path of the container in the request
+// Names must be unique IANA_SVC_NAMEs, and each port number can only be used once per protocol
+func buildPorts(field string, portStructs []config.PortStruct) ([]apiv1.ContainerPort, error) {
+	ports := []apiv1.ContainerPort{}
+	names := map[string]bool{}
+	numbers := map[string]bool{}
+	for i, portStruct := range portStructs {
+		portField := fmt.Sprintf("%sports[%d]", field, i)
+		if errs := validation.IsValidPortName(portStruct.Name); len(errs) > 0 {
+			return nil, newFieldError(portField+".name", "%q is invalid: %s", portStruct.Name, strings.Join(errs, ", "))
+		}
+		if names[portStruct.Name] {
+			return nil, newFieldError(portField+".name", "%q is used by more than one port", portStruct.Name)
+		}
+		names[portStruct.Name] = true
+
+		containerPort, err := parsePortField(portField+".containerPort", portStruct.ContainerPort)
+		if err != nil {
+			return nil, err
+		}
+		protocol := apiv1.Protocol(strings.ToUpper(portStruct.Protocol))
+		switch protocol {
+		case "":
+			protocol = apiv1.ProtocolTCP
+		case apiv1.ProtocolTCP, apiv1.ProtocolUDP, apiv1.ProtocolSCTP:
+		default:
+			return nil, newFieldError(portField+".protocol", "must be one of TCP, UDP or SCTP, got %q", portStruct.Protocol)
+		}
+		key := fmt.Sprintf("%d/%s", containerPort, protocol)
+		if numbers[key] {
+			return nil, newFieldError(portField+".containerPort", "%s is used by more than one port", key)
+		}
+		numbers[key] = true
+
+		port := apiv1.ContainerPort{Name: portStruct.Name, ContainerPort: containerPort, Protocol: protocol}
+		if portStruct.HostPort != "" {
+			if port.HostPort, err = parsePortField(portField+".hostPort", portStruct.HostPort); err != nil {
+				return nil, err
+			}
+		}
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
+
+// Parse a required port number, which must be between 1 and 65535
+func parsePortField(field string, value string) (int32, error) {
+	port, err := parseInt32Field(field, value, 1)
+	if err != nil {
+		return 0, err
+	}
+	if errs := validation.IsValidPortNum(int(port)); len(errs) > 0 {
+		return 0, newFieldError(field, "%s", strings.Join(errs, ", "))
+	}
+
+	return port, nil
+}
+
+// Set the resource requests and limits of a container - field is the path of the container in the request
+// Only the quantities provided are changed, and the result is checked so no request is larger than its limit
+func applyResources(field string, container *apiv1.Container, containerStruct config.ContainerStruct) error {
+	quantities := []struct {
+		name     string
+		value    string
+		resource apiv1.ResourceName
+		request  bool
+	}{
+		{"cpu", containerStruct.CPU, apiv1.ResourceCPU, false},
+		{"memory", containerStruct.Memory, apiv1.ResourceMemory, false},
+		{"ephemeralStorage", containerStruct.EphemeralStorage, apiv1.ResourceEphemeralStorage, false},
+		{"cpuRequest", containerStruct.CPURequest, apiv1.ResourceCPU, true},
+		{"memoryRequest", containerStruct.MemoryRequest, apiv1.ResourceMemory, true},
+		{"ephemeralStorageRequest", containerStruct.EphemeralStorageRequest, apiv1.ResourceEphemeralStorage, true},
+	}
+	for _, quantity := range quantities {
+		if quantity.value == "" {
+			continue
+		}
+		// ParseQuantity is used rather than MustParse so a typo such as "500mb" is returned to the client instead of panicking
+		parsed, err := resource.ParseQuantity(quantity.value)
+		if err != nil {
+			return newFieldError(field+quantity.name, "%q is not a valid quantity, e.g. 500m or 256Mi", quantity.value)
+		}
+		if parsed.Sign() < 0 {
+			return newFieldError(field+quantity.name, "can't be negative, got %q", quantity.value)
+		}
+		list := &container.Resources.Limits
+		if quantity.request {
+			list = &container.Resources.Requests
+		}
+		if *list == nil {
+			*list = apiv1.ResourceList{}
+		}
+		(*list)[quantity.resource] = parsed
+	}
+
+	for _, quantity := range quantities {
+		if !quantity.request {
+			continue
+		}
+		request, hasRequest := container.Resources.Requests[quantity.resource]
+		limit, hasLimit := container.Resources.Limits[quantity.resource]
+		if hasRequest && hasLimit && request.Cmp(limit) > 0 {
+			return newFieldError(field+quantity.name, "%s is larger than the %s limit of %s", request.String(), quantity.resource, limit.String())
+		}
+	}
+
+	return nil
+}
+
+// Validate the environment variables of a container - field is the path of the container in the request
+func validateEnv(field string, env []apiv1.EnvVar, envFrom []apiv1.EnvFromSource) error {
+	names := map[string]bool{}
+	for i, envVar := range env {
+		envField := fmt.Sprintf("%senv[%d]", field, i)
+		if errs := validation.IsEnvVarName(envVar.Name); len(errs) > 0 {
+			return newFieldError(envField+".name", "%q is invalid: %s", envVar.Name, strings.Join(errs, ", "))
+		}
+		if names[envVar.Name] {
+			return newFieldError(envField+".name", "%q is set more than once", envVar.Name)
+		}
+		names[envVar.Name] = true
+		if envVar.ValueFrom == nil {
+			continue
+		}
+		if envVar.Value != "" {
+			return newFieldError(envField+".value", "can't be set together with valueFrom")
+		}
+
+		// Exactly one source has to be set
+		valueFrom := envVar.ValueFrom
+		sources := 0
+		if ref := valueFrom.ConfigMapKeyRef; ref != nil {
+			sources++
+			if ref.Name == "" || ref.Key == "" {
+				return newFieldError(envField+".valueFrom.configMapKeyRef", "name and key are required")
+			}
+		}
+		if ref := valueFrom.SecretKeyRef; ref != nil {
+			sources++
+			if ref.Name == "" || ref.Key == "" {
+				return newFieldError(envField+".valueFrom.secretKeyRef", "name and key are required")
+			}
+		}
+		if ref := valueFrom.FieldRef; ref != nil {
+			sources++
+			if ref.FieldPath == "" {
+				return newFieldError(envField+".valueFrom.fieldRef.fieldPath", "is required")
+			}
+		}
+		if ref := valueFrom.ResourceFieldRef; ref != nil {
+			sources++
+			if ref.Resource == "" {
+				return newFieldError(envField+".valueFrom.resourceFieldRef.resource", "is required")
+			}
+		}
+		if sources != 1 {
+			return newFieldError(envField+".valueFrom", "must set exactly one of configMapKeyRef, secretKeyRef, fieldRef or resourceFieldRef")
+		}
+	}
+
+	for i, source := range envFrom {
+		sourceField := fmt.Sprintf("%senvFrom[%d]", field, i)
+		if source.Prefix != "" {
+			if errs := validation.IsEnvVarName(source.Prefix); len(errs) > 0 {
+				return newFieldError(sourceField+".prefix", "%q is invalid: %s", source.Prefix, strings.Join(errs, ", "))
+			}
+		}
+		if (source.ConfigMapRef == nil) == (source.SecretRef == nil) {
+			return newFieldError(sourceField, "must set exactly one of configMapRef or secretRef")
+		}
+		if source.ConfigMapRef != nil && source.ConfigMapRef.Name == "" {
+			return newFieldError(sourceField+".configMapRef.name", "is required")
+		}
+		if source.SecretRef != nil && source.SecretRef.Name == "" {
+			return newFieldError(sourceField+".secretRef.name", "is required")
+		}
+	}
+
+	return nil
+}
+
+// Collect the credentials of every container using a private registry, keyed by registry server
+// These are all stored in the deployment's single image pull secret
+func registryAuths(createDeploymentStruct config.CreateDeploymentStruct) (map[string]config.RegistryAuth, error) {
+	auths := map[string]config.RegistryAuth{}
+
+	fields, containers := requestContainers(createDeploymentStruct)
+	for i, containerStruct := range createDeploymentStruct.InitContainers {
+		fields = append(fields, fmt.Sprintf("initContainers[%d].", i))
+		containers = append(containers, containerStruct)
+	}
+	for i, containerStruct := range containers {
+		if containerStruct.RegistryType != "private" {
+			continue
+		}
+		auth := config.RegistryAuth{Username: containerStruct.RegistryUsername, Password: containerStruct.RegistryPassword}
+		// Containers pulling from the same registry have to use the same credentials, as there is one entry per registry server
+		if existing, ok := auths[containerStruct.ContainerRegistryServer]; ok && existing != auth {
+			return nil, newFieldError(fields[i]+"registryUsername", "conflicts with the credentials of another container using registry %s", containerStruct.ContainerRegistryServer)
+		}
+		auths[containerStruct.ContainerRegistryServer] = auth
+	}
+
+	return auths, nil
+}
+
+// Set the rollout strategy and related settings on the deployment
+// Anything that isn't provided is left empty so the Kubernetes defaults are used
+func applyRolloutStrategy(deployment *appsv1.Deployment, createDeploymentStruct config.CreateDeploymentStruct) error {
+	var err error
+	if deployment.Spec.RevisionHistoryLimit, err = parseOptionalInt32Field("revisionHistoryLimit", createDeploymentStruct.RevisionHistoryLimit, 0); err != nil {
+		return err
+	}
+	minReadySeconds, err := parseOptionalInt32Field("minReadySeconds", createDeploymentStruct.MinReadySeconds, 0)
+	if err != nil {
+		return err
+	}
+	if minReadySeconds != nil {
+		deployment.Spec.MinReadySeconds = *minReadySeconds
+	}
+	if deployment.Spec.ProgressDeadlineSeconds, err = parseOptionalInt32Field("progressDeadlineSeconds", createDeploymentStruct.ProgressDeadlineSeconds, 1); err != nil {
+		return err
+	}
+	// The API server rejects a progress deadline that isn't longer than minReadySeconds - the default deadline is 600 seconds
+	progressDeadlineSeconds := int32(600)
+	if deployment.Spec.ProgressDeadlineSeconds != nil {
+		progressDeadlineSeconds = *deployment.Spec.ProgressDeadlineSeconds
+	}
+	if progressDeadlineSeconds <= deployment.Spec.MinReadySeconds {
+		return newFieldError("progressDeadlineSeconds", "must be greater than minReadySeconds (%d), got %d", deployment.Spec.MinReadySeconds, progressDeadlineSeconds)
+	}
+
+	switch appsv1.DeploymentStrategyType(createDeploymentStruct.Strategy) {
+	case appsv1.RecreateDeploymentStrategyType:
+		// Recreate deletes all existing pods before creating new ones, so surge and unavailability don't apply
+		if createDeploymentStruct.MaxSurge != "" {
+			return newFieldError("maxSurge", "can only be set with the RollingUpdate strategy")
+		}
+		if createDeploymentStruct.MaxUnavailable != "" {
+			return newFieldError("maxUnavailable", "can only be set with the RollingUpdate strategy")
+		}
+		deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	case "", appsv1.RollingUpdateDeploymentStrategyType:
+		maxSurge, err := parseIntOrPercentField("maxSurge", createDeploymentStruct.MaxSurge)
+		if err != nil {
+			return err
+		}
+		maxUnavailable, err := parseIntOrPercentField("maxUnavailable", createDeploymentStruct.MaxUnavailable)
+		if err != nil {
+			return err
+		}
+		// Pods can surge above 100%, but no more than all of them can be unavailable
+		if maxUnavailable != nil && maxUnavailable.Type == intstr.String {
+			if percent, _ := strconv.Atoi(strings.TrimSuffix(maxUnavailable.StrVal, "%")); percent > 100 {
+				return newFieldError("maxUnavailable", "can't be more than 100%%, got %q", maxUnavailable.StrVal)
+			}
+		}
+		// A rollout can't make progress if it can neither add nor remove pods
+		if isZeroIntOrPercent(maxSurge) && isZeroIntOrPercent(maxUnavailable) {
+			return newFieldError("maxUnavailable", "can't be 0 when maxSurge is also 0")
+		}
+		if createDeploymentStruct.Strategy != "" || maxSurge != nil || maxUnavailable != nil {
+			deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}
+		}
+		if maxSurge != nil || maxUnavailable != nil {
+			deployment.Spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{MaxSurge: maxSurge, MaxUnavailable: maxUnavailable}
+		}
+	default:
+		return newFieldError("strategy", "must be either RollingUpdate or Recreate, got %q", createDeploymentStruct.Strategy)
+	}
+
+	return nil
+}
+
+// Parse an optional field that is either a number of pods, e.g. "1", or a percentage, e.g. "25%"
+func parseIntOrPercentField(field string, value string) (*intstr.IntOrString, error) {
+	if value == "" {
+		return nil, nil
+	}
+	match := intOrPercentRegex.FindStringSubmatch(value)
+	if match == nil {
+		return nil, newFieldError(field, "must be a number or a percentage, got %q", value)
+	}
+	number, err := strconv.ParseInt(match[1], 10, 32)
+	if err != nil {
+		return nil, newFieldError(field, "must be a number or a percentage, got %q", value)
+	}
+	if value[len(value)-1] == '%' {
+		parsed := intstr.FromString(value)
+		return &parsed, nil
+	}
+
+	parsed := intstr.FromInt32(int32(number))
+	return &parsed, nil
+}
+
+// Check if a parsed maxSurge or maxUnavailable is explicitly 0 or 0%
+func isZeroIntOrPercent(value *intstr.IntOrString) bool {
+	if value == nil {
+		return false
+	}
+	if value.Type == intstr.String {
+		return value.StrVal == "0%"
+	}
+
+	return value.IntVal == 0
+}
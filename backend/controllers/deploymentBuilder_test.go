@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestParseIntOrPercentField(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *intstr.IntOrString
+		wantErr string
+	}{
+		{value: "", want: nil},
+		{value: "0", want: &intstr.IntOrString{Type: intstr.Int, IntVal: 0}},
+		{value: "3", want: &intstr.IntOrString{Type: intstr.Int, IntVal: 3}},
+		{value: "25%", want: &intstr.IntOrString{Type: intstr.String, StrVal: "25%"}},
+		{value: "0%", want: &intstr.IntOrString{Type: intstr.String, StrVal: "0%"}},
+		{value: "-1", wantErr: `maxSurge: must be a number or a percentage, got "-1"`},
+		{value: "25 %", wantErr: `maxSurge: must be a number or a percentage, got "25 %"`},
+		{value: "%", wantErr: `maxSurge: must be a number or a percentage, got "%"`},
+		{value: "1.5", wantErr: `maxSurge: must be a number or a percentage, got "1.5"`},
+		{value: "99999999999", wantErr: `maxSurge: must be a number or a percentage, got "99999999999"`},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseIntOrPercentField("maxSurge", test.value)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil) != (test.want == nil) || got != nil && *got != *test.want {
+				t.Errorf("parseIntOrPercentField(%q) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     []apiv1.EnvVar
+		envFrom []apiv1.EnvFromSource
+		wantErr string
+	}{
+		{
+			name: "values and sources",
+			env: []apiv1.EnvVar{
+				{Name: "FEATURE_X", Value: "on"},
+				{Name: "DATABASE_URL", ValueFrom: &apiv1.EnvVarSource{SecretKeyRef: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "db"}, Key: "url"}}},
+				{Name: "POD_IP", ValueFrom: &apiv1.EnvVarSource{FieldRef: &apiv1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+			},
+			envFrom: []apiv1.EnvFromSource{{Prefix: "APP_", ConfigMapRef: &apiv1.ConfigMapEnvSource{LocalObjectReference: apiv1.LocalObjectReference{Name: "flags"}}}},
+		},
+		{
+			name:    "invalid name",
+			env:     []apiv1.EnvVar{{Name: "1X"}},
+			wantErr: `env[0].name: "1X" is invalid`,
+		},
+		{
+			name:    "duplicate name",
+			env:     []apiv1.EnvVar{{Name: "X", Value: "1"}, {Name: "X", Value: "2"}},
+			wantErr: `env[1].name: "X" is set more than once`,
+		},
+		{
+			name:    "value and valueFrom",
+			env:     []apiv1.EnvVar{{Name: "X", Value: "1", ValueFrom: &apiv1.EnvVarSource{FieldRef: &apiv1.ObjectFieldSelector{FieldPath: "metadata.name"}}}},
+			wantErr: "env[0].value: can't be set together with valueFrom",
+		},
+		{
+			name:    "no source",
+			env:     []apiv1.EnvVar{{Name: "X", ValueFrom: &apiv1.EnvVarSource{}}},
+			wantErr: "env[0].valueFrom: must set exactly one of configMapKeyRef, secretKeyRef, fieldRef or resourceFieldRef",
+		},
+		{
+			name: "two sources",
+			env: []apiv1.EnvVar{{Name: "X", ValueFrom: &apiv1.EnvVarSource{
+				FieldRef:         &apiv1.ObjectFieldSelector{FieldPath: "metadata.name"},
+				ResourceFieldRef: &apiv1.ResourceFieldSelector{Resource: "limits.cpu"},
+			}}},
+			wantErr: "env[0].valueFrom: must set exactly one of configMapKeyRef, secretKeyRef, fieldRef or resourceFieldRef",
+		},
+		{
+			name:    "secretKeyRef without a key",
+			env:     []apiv1.EnvVar{{Name: "X", ValueFrom: &apiv1.EnvVarSource{SecretKeyRef: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "db"}}}}},
+			wantErr: "env[0].valueFrom.secretKeyRef: name and key are required",
+		},
+		{
+			name:    "envFrom with both sources",
+			envFrom: []apiv1.EnvFromSource{{ConfigMapRef: &apiv1.ConfigMapEnvSource{}, SecretRef: &apiv1.SecretEnvSource{}}},
+			wantErr: "envFrom[0]: must set exactly one of configMapRef or secretRef",
+		},
+		{
+			name:    "envFrom without a name",
+			envFrom: []apiv1.EnvFromSource{{SecretRef: &apiv1.SecretEnvSource{}}},
+			wantErr: "envFrom[0].secretRef.name: is required",
+		},
+		{
+			name:    "invalid envFrom prefix",
+			envFrom: []apiv1.EnvFromSource{{Prefix: "1_", ConfigMapRef: &apiv1.ConfigMapEnvSource{LocalObjectReference: apiv1.LocalObjectReference{Name: "flags"}}}},
+			wantErr: `envFrom[0].prefix: "1_" is invalid`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateEnv("", test.env, test.envFrom)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+				t.Fatalf("error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyResources(t *testing.T) {
+	tests := []struct {
+		name         string
+		existing     apiv1.ResourceRequirements
+		resources    config.ContainerStruct
+		wantLimits   apiv1.ResourceList
+		wantRequests apiv1.ResourceList
+		wantErr      string
+	}{
+		{
+			name:         "limits and requests",
+			resources:    config.ContainerStruct{CPU: "1", Memory: "256Mi", CPURequest: "250m", MemoryRequest: "128Mi", EphemeralStorage: "1Gi"},
+			wantLimits:   apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1"), apiv1.ResourceMemory: resource.MustParse("256Mi"), apiv1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+			wantRequests: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("250m"), apiv1.ResourceMemory: resource.MustParse("128Mi")},
+		},
+		{
+			name:         "only the provided quantities change",
+			existing:     apiv1.ResourceRequirements{Limits: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1"), apiv1.ResourceMemory: resource.MustParse("256Mi")}},
+			resources:    config.ContainerStruct{Memory: "512Mi"},
+			wantLimits:   apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1"), apiv1.ResourceMemory: resource.MustParse("512Mi")},
+			wantRequests: nil,
+		},
+		{
+			name:      "invalid quantity",
+			resources: config.ContainerStruct{Memory: "500mb"},
+			wantErr:   `memory: "500mb" is not a valid quantity, e.g. 500m or 256Mi`,
+		},
+		{
+			name:      "negative quantity",
+			resources: config.ContainerStruct{CPURequest: "-1"},
+			wantErr:   `cpuRequest: can't be negative, got "-1"`,
+		},
+		{
+			name:      "request larger than its limit",
+			resources: config.ContainerStruct{Memory: "128Mi", MemoryRequest: "256Mi"},
+			wantErr:   "memoryRequest: 256Mi is larger than the memory limit of 128Mi",
+		},
+		{
+			name:      "request larger than an existing limit",
+			existing:  apiv1.ResourceRequirements{Limits: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("500m")}},
+			resources: config.ContainerStruct{CPURequest: "1"},
+			wantErr:   "cpuRequest: 1 is larger than the cpu limit of 500m",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := &apiv1.Container{Resources: *test.existing.DeepCopy()}
+			err := applyResources("", container, test.resources)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalResources(container.Resources.Limits, test.wantLimits) {
+				t.Errorf("limits = %v, want %v", container.Resources.Limits, test.wantLimits)
+			}
+			if !equalResources(container.Resources.Requests, test.wantRequests) {
+				t.Errorf("requests = %v, want %v", container.Resources.Requests, test.wantRequests)
+			}
+		})
+	}
+}
+
+// Compare resource lists by their quantities' values, since equal quantities can be formatted differently
+func equalResources(got apiv1.ResourceList, want apiv1.ResourceList) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for name, quantity := range want {
+		if value, ok := got[name]; !ok || value.Cmp(quantity) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestBuildPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   []config.PortStruct
+		want    []apiv1.ContainerPort
+		wantErr string
+	}{
+		{
+			name: "protocols default to TCP",
+			ports: []config.PortStruct{
+				{Name: "http", ContainerPort: "8080"},
+				{Name: "dns", ContainerPort: "53", Protocol: "udp"},
+				{Name: "metrics", ContainerPort: "9090", HostPort: "9090"},
+			},
+			want: []apiv1.ContainerPort{
+				{Name: "http", ContainerPort: 8080, Protocol: apiv1.ProtocolTCP},
+				{Name: "dns", ContainerPort: 53, Protocol: apiv1.ProtocolUDP},
+				{Name: "metrics", ContainerPort: 9090, HostPort: 9090, Protocol: apiv1.ProtocolTCP},
+			},
+		},
+		{
+			name:  "the same number with different protocols",
+			ports: []config.PortStruct{{Name: "dns-tcp", ContainerPort: "53"}, {Name: "dns-udp", ContainerPort: "53", Protocol: "UDP"}},
+			want:  []apiv1.ContainerPort{{Name: "dns-tcp", ContainerPort: 53, Protocol: apiv1.ProtocolTCP}, {Name: "dns-udp", ContainerPort: 53, Protocol: apiv1.ProtocolUDP}},
+		},
+		{
+			name:    "invalid name",
+			ports:   []config.PortStruct{{Name: "Not_Valid", ContainerPort: "80"}},
+			wantErr: `ports[0].name: "Not_Valid" is invalid`,
+		},
+		{
+			name:    "duplicate name",
+			ports:   []config.PortStruct{{Name: "http", ContainerPort: "80"}, {Name: "http", ContainerPort: "8080"}},
+			wantErr: `ports[1].name: "http" is used by more than one port`,
+		},
+		{
+			name:    "duplicate number and protocol",
+			ports:   []config.PortStruct{{Name: "a", ContainerPort: "80"}, {Name: "b", ContainerPort: "80", Protocol: "tcp"}},
+			wantErr: "ports[1].containerPort: 80/TCP is used by more than one port",
+		},
+		{
+			name:    "port out of range",
+			ports:   []config.PortStruct{{Name: "http", ContainerPort: "65536"}},
+			wantErr: "ports[0].containerPort: must be between 1 and 65535, inclusive",
+		},
+		{
+			name:    "missing port",
+			ports:   []config.PortStruct{{Name: "http"}},
+			wantErr: "ports[0].containerPort: is required",
+		},
+		{
+			name:    "invalid protocol",
+			ports:   []config.PortStruct{{Name: "http", ContainerPort: "80", Protocol: "HTTP"}},
+			wantErr: `ports[0].protocol: must be one of TCP, UDP or SCTP, got "HTTP"`,
+		},
+		{
+			name:    "invalid host port",
+			ports:   []config.PortStruct{{Name: "http", ContainerPort: "80", HostPort: "0"}},
+			wantErr: "ports[0].hostPort: must be at least 1, got 0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := buildPorts("", test.ports)
+			if test.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("buildPorts() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInitContainerProbes(t *testing.T) {
+	probe := &config.ProbeStruct{Type: "tcpSocket", Port: "8080"}
+	tests := []struct {
+		name          string
+		initContainer config.ContainerStruct
+		wantErr       string
+	}{
+		{name: "no probes", initContainer: config.ContainerStruct{}},
+		{name: "liveness probe", initContainer: config.ContainerStruct{LivenessProbe: probe}, wantErr: "initContainers[0].livenessProbe: isn't supported on init containers"},
+		{name: "readiness probe", initContainer: config.ContainerStruct{ReadinessProbe: probe}, wantErr: "initContainers[0].readinessProbe: isn't supported on init containers"},
+		{name: "startup probe", initContainer: config.ContainerStruct{StartupProbe: probe}, wantErr: "initContainers[0].startupProbe: isn't supported on init containers"},
+		{name: "health path", initContainer: config.ContainerStruct{HealthPath: "/healthz"}, wantErr: "initContainers[0].healthPath: isn't supported on init containers"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			initContainer := test.initContainer
+			initContainer.ContainerName = "migrate"
+			initContainer.ContainerImageName = "busybox"
+			initContainer.ContainerPort = "8080"
+			createDeploymentStruct := config.CreateDeploymentStruct{
+				DeploymentName:  "web",
+				ReplicaCount:    "1",
+				ContainerStruct: config.ContainerStruct{ContainerName: "web", ContainerImageName: "nginx", ContainerImageTag: "1.27", ContainerPort: "80"},
+				InitContainers:  []config.ContainerStruct{initContainer},
+			}
+			updateDeploymentStruct := config.CreateDeploymentStruct{InitContainers: []config.ContainerStruct{initContainer}}
+
+			for operation, err := range map[string]error{
+				"create": func() error { _, err := buildDeployment(createDeploymentStruct); return err }(),
+				"update": validateDeploymentUpdate("web", updateDeploymentStruct),
+			} {
+				if test.wantErr == "" {
+					if err != nil {
+						t.Errorf("%s: %v", operation, err)
+					}
+					continue
+				}
+				if err == nil || err.Error() != test.wantErr || errorStatus(err) != 400 {
+					t.Errorf("%s: error = %v, want a 400 %q", operation, err, test.wantErr)
+				}
+			}
+		})
+	}
+}
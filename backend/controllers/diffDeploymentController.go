@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"strconv"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Show what a change would do to a deployment, without making it
+// The body is either the same fields as PATCH /deployment/:deployment, or a YAML or JSON manifest of the deployment as it would be applied by POST /apply - see isManifest
+// The change is sent to the API server as a dry run, so the proposed deployment has its defaults filled in and has been through admission webhooks
+// Both sides are compared as clean manifests - see cleanDeploymentManifest - and returned as a list of changed fields and a unified diff
+// Changes to the image pull secret itself aren't shown, only the deployment's reference to it
+func DiffDeployment(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+
+	// The deployment is read from the API server rather than the cache, so it's compared against the latest version
+	live, err := cluster.Clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var proposed map[string]interface{}
+	if isManifest(c.Get(fiber.HeaderContentType), c.Body()) {
+		force := false
+		if c.Query("force") != "" {
+			if force, err = strconv.ParseBool(c.Query("force")); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "force must be true or false"})
+			}
+		}
+		proposed, err = dryRunApplyDeployment(cluster, namespace, deploymentName, c.Body(), force)
+	} else {
+		var updateDeploymentStruct = config.CreateDeploymentStruct{}
+		// Parse the request body into the updateDeploymentStruct struct
+		if err := c.BodyParser(&updateDeploymentStruct); err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		proposed, err = dryRunUpdateDeployment(cluster, namespace, live, updateDeploymentStruct)
+	}
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	current, err := cleanDeploymentManifest(live)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	currentYAML, err := yaml.Marshal(current)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	proposedYAML, err := yaml.Marshal(proposed)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	changes := diffManifests("", current, proposed)
+	zap.L().Info("Deployment " + deploymentName + " has " + strconv.Itoa(len(changes)) + " changed fields")
+
+	return c.JSON(fiber.Map{
+		"deployment": deploymentName,
+		"changed":    len(changes) > 0,
+		"changes":    changes,
+		"diff":       unifiedDiff("live", "proposed", string(currentYAML), string(proposedYAML)),
+	})
+}
+
+// Check whether a diff request body is a manifest rather than the fields of an update
+// A YAML Content-Type is always a manifest and a form is always fields - otherwise the body is a manifest if its first object has an apiVersion and kind
+func isManifest(contentType string, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return true
+	case fiber.MIMEApplicationForm, fiber.MIMEMultipartForm:
+		return false
+	}
+
+	var fields map[string]interface{}
+	if err := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096).Decode(&fields); err != nil {
+		return false
+	}
+	_, hasAPIVersion := fields["apiVersion"]
+	_, hasKind := fields["kind"]
+
+	return hasAPIVersion && hasKind
+}
+
+// Apply the fields of an update to a copy of the live deployment and send it to the API server as a dry run
+// Returns the clean manifest of the deployment as it would be stored
+func dryRunUpdateDeployment(cluster *config.Cluster, namespace string, live *appsv1.Deployment, updateDeploymentStruct config.CreateDeploymentStruct) (map[string]interface{}, error) {
+	if err := validateDeploymentUpdate(live.GetName(), updateDeploymentStruct); err != nil {
+		return nil, err
+	}
+	deployment := live.DeepCopy()
+	if _, _, err := applyDeploymentUpdate(deployment, updateDeploymentStruct); err != nil {
+		return nil, err
+	}
+	// Only the reference to the image pull secret is shown - whether the secret is removed depends on the credentials left in it
+	if updateDeploymentStruct.RegistryType == "private" {
+		deployment.Spec.Template.Spec.ImagePullSecrets = append(removeImagePullSecret(deployment.Spec.Template.Spec.ImagePullSecrets, config.ImagePullSecretName(live.GetName())), apiv1.LocalObjectReference{Name: config.ImagePullSecretName(live.GetName())})
+	}
+
+	zap.L().Info(dryRunMessage([]string{metav1.DryRunAll}, "Updating deployment "+live.GetName()))
+	result, err := cluster.Clientset.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		return nil, err
+	}
+
+	return cleanDeploymentManifest(result)
+}
+
+// Apply a manifest of the deployment with a server-side apply dry run
+// The manifest has to hold exactly one Deployment, named the same as the deployment it's compared against
+// Returns the clean manifest of the deployment as it would be stored
+func dryRunApplyDeployment(cluster *config.Cluster, namespace string, deploymentName string, body []byte, force bool) (map[string]interface{}, error) {
+	objects, err := parseManifest(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 || objects[0].GroupVersionKind() != appsv1.SchemeGroupVersion.WithKind("Deployment") {
+		return nil, apierrors.NewBadRequest("The manifest has to hold exactly one apps/v1 Deployment")
+	}
+	object := objects[0]
+	if object.GetName() != deploymentName {
+		return nil, newFieldError("metadata.name", "%q doesn't match deployment %s", object.GetName(), deploymentName)
+	}
+	if object.GetNamespace() == "" {
+		object.SetNamespace(namespace)
+	}
+	if object.GetNamespace() != namespace {
+		return nil, newFieldError("metadata.namespace", "%q doesn't match namespace %s", object.GetNamespace(), namespace)
+	}
+	prepareApply(object)
+
+	zap.L().Info(dryRunMessage([]string{metav1.DryRunAll}, "Applying Deployment "+namespace+"/"+deploymentName))
+	applied, err := cluster.Dynamic.Resource(applyableKinds[object.GroupVersionKind()]).Namespace(namespace).Apply(context.TODO(), deploymentName, object, metav1.ApplyOptions{
+		FieldManager: config.ManagedBy,
+		Force:        force,
+		DryRun:       []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Round trip the result through the typed deployment, so both sides of the diff are serialized the same way
+	result := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(applied.Object, result); err != nil {
+		return nil, err
+	}
+
+	return cleanDeploymentManifest(result)
+}
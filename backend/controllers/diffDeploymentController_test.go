@@ -0,0 +1,30 @@
+package controllers
+
+import "testing"
+
+func TestIsManifest(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"JSON fields", "application/json", `{"containerImageTag": "v2"}`, false},
+		{"JSON fields with leading whitespace", "application/json", "\n  {\"replicaCount\": \"3\"}", false},
+		{"JSON fields with a kind field", "application/json", `{"kind": "Deployment"}`, false},
+		{"JSON manifest", "application/json; charset=utf-8", `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web"}}`, true},
+		{"YAML Content-Type", "application/yaml", "apiVersion: apps/v1\nkind: Deployment\n", true},
+		{"YAML Content-Type that can't be parsed", "application/x-yaml", "- not a map", true},
+		{"form", "application/x-www-form-urlencoded", "apiVersion=apps/v1&kind=Deployment", false},
+		{"YAML without a Content-Type", "", "---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n", true},
+		{"YAML fields without a Content-Type", "", "containerImageTag: v2\n", false},
+		{"empty body", "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isManifest(test.contentType, []byte(test.body)); got != test.want {
+				t.Errorf("isManifest(%q, %q) = %v, want %v", test.contentType, test.body, got, test.want)
+			}
+		})
+	}
+}
@@ -1,23 +1,27 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
+	"strconv"
 
-	config "github.com/Ajsalemo/kubernetes-client-application/config"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
-	apiv1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/yaml"
 )
 
 // Get a specific deployment
+// ?format=yaml returns the deployment as a clean YAML manifest, and ?clean=true as a clean JSON manifest - see cleanDeploymentManifest
+// ?format=yaml&clean=false returns the deployment as YAML with the server populated fields left in
 func GetDeployments(c *fiber.Ctx) error {
-	clientset, err := config.KubeConfig()
+	cluster, err := getCluster(c)
 	if err != nil {
 		zap.L().Error(err.Error())
-		panic(err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
 	// Check if the parameter is empty - if so, return a 400 for bad request
 	if c.Params("deployment") == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
@@ -26,20 +30,70 @@ func GetDeployments(c *fiber.Ctx) error {
 	deploymentName := c.Params("deployment")
 	zap.L().Info("User provided deployment name: " + deploymentName)
 
-	deploymentsClient := clientset.AppsV1().Deployments(apiv1.NamespaceDefault)
-	listOptions := metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("metadata.name=%s", deploymentName),
+	format := c.Query("format", "json")
+	if format != "json" && format != "yaml" {
+		return c.Status(400).JSON(fiber.Map{"error": "format must be either json or yaml"})
+	}
+	// A YAML export is clean unless asked otherwise
+	clean := format == "yaml"
+	if c.Query("clean") != "" {
+		if clean, err = strconv.ParseBool(c.Query("clean")); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "clean must be true or false"})
+		}
 	}
-	getDeployment, err := deploymentsClient.List(context.TODO(), metav1.ListOptions{FieldSelector: listOptions.FieldSelector})
 
-	if err != nil {
+	// The deployment is served from the informer cache
+	// A deployment that doesn't exist returns an empty list rather than an error
+	getDeployment := []*appsv1.Deployment{}
+	deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+	// An export is a single object rather than a list, so a deployment that doesn't exist is a 404
+	if format == "yaml" || clean {
+		if err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		return exportDeployment(c, deployment, format, clean)
+	}
+	if err == nil {
+		getDeployment = append(getDeployment, deployment)
+	} else if !apierrors.IsNotFound(err) {
 		zap.L().Error(err.Error())
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 	// Log the deployment names
-	for _, d := range getDeployment.Items {
+	for _, d := range getDeployment {
 		zap.L().Info(" * " + d.GetName())
 	}
 
-	return c.JSON(fiber.Map{"deployments": getDeployment.Items})
+	return c.JSON(fiber.Map{"deployments": getDeployment})
+}
+
+// Write a deployment to the response as a manifest in the requested format
+func exportDeployment(c *fiber.Ctx, deployment *appsv1.Deployment, format string, clean bool) error {
+	var manifest interface{}
+	if clean {
+		cleaned, err := cleanDeploymentManifest(deployment)
+		if err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		manifest = cleaned
+	} else {
+		// Objects from the informer cache don't have their apiVersion and kind set
+		deployment = deployment.DeepCopy()
+		deployment.APIVersion, deployment.Kind = appsv1.SchemeGroupVersion.String(), "Deployment"
+		manifest = deployment
+	}
+	zap.L().Info("Exporting deployment " + deployment.GetName() + " as " + format)
+	if format == "json" {
+		return c.JSON(manifest)
+	}
+
+	body, err := yaml.Marshal(manifest)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "application/yaml")
+	return c.Send(body)
 }
@@ -1,22 +1,23 @@
 package controllers
 
 import (
-	"context"
+	"sort"
 
-	config "github.com/Ajsalemo/kubernetes-client-application/config"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
-	apiv1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // Get a specific deployment
 func GetPods(c *fiber.Ctx) error {
-	clientset, err := config.KubeConfig()
+	cluster, err := getCluster(c)
 	if err != nil {
 		zap.L().Error(err.Error())
-		panic(err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
 	// Check if the parameters are empty - if so, return a 400 for bad request
 	if c.Params("label") == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Label name is required"})
@@ -28,20 +29,20 @@ func GetPods(c *fiber.Ctx) error {
 	zap.L().Info("User provided deployment name: " + c.Params("deployment"))
 	zap.L().Info("User provided label name: " + c.Params("label"))
 
-	podsClient := clientset.CoreV1().Pods(apiv1.NamespaceDefault)
-	labelSelector := metav1.LabelSelector{MatchLabels: map[string]string{"app": c.Params("label"), "owner": c.Params("deployment")}}
-	listOptions := metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(&labelSelector)}
-
-	getPods, err := podsClient.List(context.TODO(), listOptions)
+	// Pods are served from the informer cache
+	labelSelector := labels.SelectorFromSet(labels.Set{"app": c.Params("label"), "owner": c.Params("deployment")})
+	getPods, err := cluster.PodLister.Pods(namespace).List(labelSelector)
 
 	if err != nil {
 		zap.L().Error(err.Error())
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	// The cache doesn't guarantee any ordering, so sort by name to keep the response stable between polls
+	sort.Slice(getPods, func(i, j int) bool { return getPods[i].Name < getPods[j].Name })
 	// Log the pod names
-	for _, d := range getPods.Items {
+	for _, d := range getPods {
 		zap.L().Info(" * " + d.GetName())
 	}
 
-	return c.JSON(fiber.Map{"pods": getPods.Items})
+	return c.JSON(fiber.Map{"pods": getPods})
 }
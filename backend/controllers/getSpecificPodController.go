@@ -1,22 +1,22 @@
 package controllers
 
 import (
-	"context"
-
-	config "github.com/Ajsalemo/kubernetes-client-application/config"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 	apiv1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // Get a specific deployment
 func GetSpecificPod(c *fiber.Ctx) error {
-	clientset, err := config.KubeConfig()
+	cluster, err := getCluster(c)
 	if err != nil {
 		zap.L().Error(err.Error())
-		panic(err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
 	// Check if the parameters are empty - if so, return a 400 for bad request
 	if c.Params("pod") == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Pod name is required"})
@@ -28,20 +28,22 @@ func GetSpecificPod(c *fiber.Ctx) error {
 	zap.L().Info("User provided deployment name: " + c.Params("deployment"))
 	zap.L().Info("User provided label name: " + c.Params("pod"))
 
-	podsClient := clientset.CoreV1().Pods(apiv1.NamespaceDefault)
-	labelSelector := metav1.LabelSelector{MatchLabels: map[string]string{"owner": c.Params("deployment")}}
-	listOptions := metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(&labelSelector), FieldSelector: "metadata.name=" + c.Params("pod")}
-
-	getPods, err := podsClient.List(context.TODO(), listOptions)
-
-	if err != nil {
+	// The pod is served from the informer cache
+	// A pod that doesn't exist, or isn't owned by the deployment, returns an empty list rather than an error
+	getPods := []*apiv1.Pod{}
+	pod, err := cluster.PodLister.Pods(namespace).Get(c.Params("pod"))
+	if err == nil {
+		if pod.Labels["owner"] == c.Params("deployment") {
+			getPods = append(getPods, pod)
+		}
+	} else if !apierrors.IsNotFound(err) {
 		zap.L().Error(err.Error())
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 	// Log the pod names
-	for _, d := range getPods.Items {
+	for _, d := range getPods {
 		zap.L().Info(" * " + d.GetName())
 	}
 
-	return c.JSON(fiber.Map{"pods": getPods.Items})
+	return c.JSON(fiber.Map{"pods": getPods})
 }
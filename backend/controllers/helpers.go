@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+// Header that can be used to target a kubeconfig context without a /api/contexts/:context path segment
+const kubeContextHeader = "X-Kube-Context"
+
+// Get the namespace for the request
+// Routes under /api/namespaces/:namespace target that namespace, the original routes without a namespace segment target "default"
+func getNamespace(c *fiber.Ctx) string {
+	if namespace := c.Params("namespace"); namespace != "" {
+		return namespace
+	}
+
+	return apiv1.NamespaceDefault
+}
+
+// Get the kubeconfig context for the request
+// The /api/contexts/:context path segment takes precedence over the X-Kube-Context header - if neither is set, the current context is used
+func getKubeContext(c *fiber.Ctx) string {
+	if kubeContext := c.Params("context"); kubeContext != "" {
+		return kubeContext
+	}
+
+	return c.Get(kubeContextHeader)
+}
+
+// Get the cluster for the kubeconfig context targeted by the request
+func getCluster(c *fiber.Ctx) (*config.Cluster, error) {
+	return config.ClusterForContext(getKubeContext(c))
+}
+
+// Get the dry run setting of a mutating request
+// With ?dryRun=true the request is sent to the API server with DryRun: All - it's validated, defaulted and run through admission webhooks, but nothing is persisted
+func getDryRun(c *fiber.Ctx) ([]string, error) {
+	value := c.Query("dryRun")
+	if value == "" {
+		return nil, nil
+	}
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, newFieldError("dryRun", "must be true or false, got %q", value)
+	}
+	if !dryRun {
+		return nil, nil
+	}
+
+	return []string{metav1.DryRunAll}, nil
+}
+
+// Mark the response message of a dry run, so it isn't mistaken for a change that was made
+func dryRunMessage(dryRun []string, message string) string {
+	if len(dryRun) > 0 {
+		return message + " (dry run)"
+	}
+
+	return message
+}
+
+// Delete an object with a dry run and return what the API server responded with
+// The typed clients discard the response of a delete - it's the object as it would be left, e.g. with a deletionTimestamp while finalizers run, or a Status once it's gone
+func dryRunDelete(restClient rest.Interface, namespace string, resource string, name string, options metav1.DeleteOptions) (runtime.Object, error) {
+	options.DryRun = []string{metav1.DryRunAll}
+	return restClient.Delete().Namespace(namespace).Resource(resource).Name(name).Body(&options).Do(context.TODO()).Get()
+}
+
+// Map an error to the HTTP status code returned to the client
+// Invalid request fields are a 400, Kubernetes API errors keep their status code (e.g. 404 or 409), an unknown context or template is a 404,
+// a template that already exists is a 409 and anything else is a 500
+func errorStatus(err error) int {
+	var fieldErr *fieldError
+	if errors.As(err, &fieldErr) {
+		return fiber.StatusBadRequest
+	}
+	if errors.Is(err, config.ErrContextNotFound) || errors.Is(err, config.ErrTemplateNotFound) {
+		return fiber.StatusNotFound
+	}
+	if errors.Is(err, config.ErrTemplateExists) {
+		return fiber.StatusConflict
+	}
+
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) && apiStatus.Status().Code != 0 {
+		return int(apiStatus.Status().Code)
+	}
+
+	return fiber.StatusInternalServerError
+}
+
+// Wait for the informer caches to observe a change made through the API server, so reads served from the caches reflect it
+// List and get requests are served from the caches, so this is called after every change for the client to see it straight away - except
+// after a dry run, which doesn't change anything for the caches to observe
+// This gives up after a few seconds - the change has still been made, the caches are just late to observe it
+func waitForCache(observed func() bool) {
+	err := wait.PollUntilContextTimeout(context.TODO(), 100*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		return observed(), nil
+	})
+	if err != nil {
+		zap.L().Warn("Timed out waiting for the informer cache to observe the change")
+	}
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// List all kubeconfig contexts
+func ListContexts(c *fiber.Ctx) error {
+	contexts, err := config.KubeContexts()
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	zap.L().Info("Contexts:")
+	for _, k := range contexts {
+		zap.L().Info(" * " + k.Name)
+	}
+
+	return c.JSON(fiber.Map{"contexts": contexts})
+}
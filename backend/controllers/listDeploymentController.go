@@ -1,35 +1,37 @@
 package controllers
 
 import (
-	"context"
+	"sort"
 
-	config "github.com/Ajsalemo/kubernetes-client-application/config"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
-	apiv1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // List all Deployments
 func ListDeployments(c *fiber.Ctx) error {
-	clientset, err := config.KubeConfig()
+	cluster, err := getCluster(c)
 	if err != nil {
 		zap.L().Error(err.Error())
-		panic(err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
 
-	deploymentsClient := clientset.AppsV1().Deployments(apiv1.NamespaceDefault)
-	list, err := deploymentsClient.List(context.TODO(), metav1.ListOptions{})
+	// Deployments are served from the informer cache
+	list, err := cluster.DeploymentLister.Deployments(namespace).List(labels.Everything())
 
 	if err != nil {
 		zap.L().Error(err.Error())
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
+	// The cache doesn't guarantee any ordering, so sort by name to keep the response stable between polls
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
 
 	zap.L().Info("Deployments:")
-	for _, d := range list.Items {
+	for _, d := range list {
 		zap.L().Info(" * " + d.Name)
 	}
 
-	return c.JSON(fiber.Map{"deployments": list.Items})
+	return c.JSON(fiber.Map{"deployments": list})
 }
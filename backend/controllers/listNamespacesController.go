@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// List all Namespaces
+func ListNamespaces(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	namespacesClient := cluster.Clientset.CoreV1().Namespaces()
+	list, err := namespacesClient.List(context.TODO(), metav1.ListOptions{})
+
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	zap.L().Info("Namespaces:")
+	for _, n := range list.Items {
+		zap.L().Info(" * " + n.Name)
+	}
+
+	return c.JSON(fiber.Map{"namespaces": list.Items})
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Annotations the API server and tools add to a deployment, which aren't part of what the user asked for
+var serverAnnotations = []string{
+	"deployment.kubernetes.io/revision",
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// Convert a deployment into a clean manifest - the fields the API server populates, such as status, uid,
+// resourceVersion and managedFields, are removed so it can be committed to git or applied to another cluster
+func cleanDeploymentManifest(deployment *appsv1.Deployment) (map[string]interface{}, error) {
+	// Objects from the informer cache don't have their apiVersion and kind set
+	deployment = deployment.DeepCopy()
+	deployment.APIVersion, deployment.Kind = appsv1.SchemeGroupVersion.String(), "Deployment"
+
+	manifest, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp", "deletionGracePeriodSeconds", "managedFields", "ownerReferences", "selfLink"} {
+		unstructured.RemoveNestedField(manifest, "metadata", field)
+	}
+	for _, annotation := range serverAnnotations {
+		unstructured.RemoveNestedField(manifest, "metadata", "annotations", annotation)
+	}
+	if annotations, _, _ := unstructured.NestedMap(manifest, "metadata", "annotations"); len(annotations) == 0 {
+		unstructured.RemoveNestedField(manifest, "metadata", "annotations")
+	}
+	unstructured.RemoveNestedField(manifest, "spec", "template", "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(manifest, "status")
+
+	return manifest, nil
+}
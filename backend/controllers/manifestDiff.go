@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Lines of unchanged context shown around each change in a unified diff
+const diffContextLines = 3
+
+// A single change between two manifests
+type manifestChange struct {
+	// The path of the field that changed, e.g. spec.template.spec.containers[0].image
+	Path string `json:"path"`
+	// Either added, removed or changed
+	Op  string      `json:"op"`
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// Compare two manifests field by field
+// Maps are compared key by key and lists index by index - a field that's a different type in each is reported as changed as a whole
+func diffManifests(path string, old interface{}, new interface{}) []manifestChange {
+	changes := []manifestChange{}
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := []string{}
+		for key := range oldMap {
+			keys = append(keys, key)
+		}
+		for key := range newMap {
+			if _, ok := oldMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+			oldValue, inOld := oldMap[key]
+			newValue, inNew := newMap[key]
+			switch {
+			case !inOld:
+				changes = append(changes, manifestChange{Path: field, Op: "added", New: newValue})
+			case !inNew:
+				changes = append(changes, manifestChange{Path: field, Op: "removed", Old: oldValue})
+			default:
+				changes = append(changes, diffManifests(field, oldValue, newValue)...)
+			}
+		}
+		return changes
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		for i := 0; i < len(oldList) || i < len(newList); i++ {
+			field := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(oldList):
+				changes = append(changes, manifestChange{Path: field, Op: "added", New: newList[i]})
+			case i >= len(newList):
+				changes = append(changes, manifestChange{Path: field, Op: "removed", Old: oldList[i]})
+			default:
+				changes = append(changes, diffManifests(field, oldList[i], newList[i])...)
+			}
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		changes = append(changes, manifestChange{Path: path, Op: "changed", Old: old, New: new})
+	}
+
+	return changes
+}
+
+// Write a unified diff of two texts, the same format as `diff -u`
+// The lines are matched up by their longest common subsequence, which is fine for the size of a manifest
+func unifiedDiff(oldName string, newName string, oldText string, newText string) string {
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+
+	// common[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	common := make([][]int, len(oldLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table to get each line as kept (' '), removed ('-') or added ('+')
+	type diffLine struct {
+		op   byte
+		text string
+	}
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			lines = append(lines, diffLine{' ', oldLines[i]})
+			i++
+			j++
+		case j < len(newLines) && (i == len(oldLines) || common[i][j+1] > common[i+1][j]):
+			lines = append(lines, diffLine{'+', newLines[j]})
+			j++
+		default:
+			lines = append(lines, diffLine{'-', oldLines[i]})
+			i++
+		}
+	}
+
+	var diff strings.Builder
+	// Group the changes into hunks, with up to diffContextLines unchanged lines around each one
+	// Changes closer together than twice the context share a hunk
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		from := start - diffContextLines
+		if from < 0 {
+			from = 0
+		}
+		to := start
+		for unchanged := 0; to < len(lines) && unchanged <= 2*diffContextLines; to++ {
+			if lines[to].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		// Trim the trailing context back down to diffContextLines
+		for to > start && lines[to-1].op == ' ' {
+			to--
+		}
+		to += diffContextLines
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		// Line numbers in the hunk header count from 1
+		oldStart, newStart := 1, 1
+		for _, line := range lines[:from] {
+			if line.op != '+' {
+				oldStart++
+			}
+			if line.op != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+		// An empty side of a hunk starts at the line before it, the same as diff -u
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
+		if diff.Len() == 0 {
+			fmt.Fprintf(&diff, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&diff, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, line := range lines[from:to] {
+			diff.WriteByte(line.op)
+			diff.WriteString(line.text)
+			diff.WriteByte('\n')
+		}
+		start = to
+	}
+
+	return diff.String()
+}
+
+// Split a text into lines, without a trailing empty line for the final newline
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
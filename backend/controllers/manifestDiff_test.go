@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Join lines into a text with a trailing newline, the way a YAML manifest is written
+func lines(l ...string) string {
+	return strings.Join(l, "\n") + "\n"
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "no changes",
+			old:  lines("a", "b", "c"),
+			new:  lines("a", "b", "c"),
+			want: "",
+		},
+		{
+			name: "change in the middle has 3 lines of context",
+			old:  lines("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			new:  lines("1", "2", "3", "4", "five", "6", "7", "8", "9"),
+			want: lines("--- live", "+++ proposed", "@@ -2,7 +2,7 @@", " 2", " 3", " 4", "-5", "+five", " 6", " 7", " 8"),
+		},
+		{
+			name: "change at the start has no leading context",
+			old:  lines("1", "2", "3", "4", "5", "6"),
+			new:  lines("one", "2", "3", "4", "5", "6"),
+			want: lines("--- live", "+++ proposed", "@@ -1,4 +1,4 @@", "-1", "+one", " 2", " 3", " 4"),
+		},
+		{
+			name: "line added at the end",
+			old:  lines("1", "2", "3", "4", "5"),
+			new:  lines("1", "2", "3", "4", "5", "6"),
+			want: lines("--- live", "+++ proposed", "@@ -3,3 +3,4 @@", " 3", " 4", " 5", "+6"),
+		},
+		{
+			name: "changes 6 lines apart share a hunk",
+			old:  lines("1", "2", "3", "4", "5", "6", "7", "8"),
+			new:  lines("one", "2", "3", "4", "5", "6", "7", "eight"),
+			want: lines("--- live", "+++ proposed", "@@ -1,8 +1,8 @@", "-1", "+one", " 2", " 3", " 4", " 5", " 6", " 7", "-8", "+eight"),
+		},
+		{
+			name: "changes 7 lines apart get their own hunks",
+			old:  lines("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			new:  lines("one", "2", "3", "4", "5", "6", "7", "8", "nine"),
+			want: lines("--- live", "+++ proposed", "@@ -1,4 +1,4 @@", "-1", "+one", " 2", " 3", " 4", "@@ -6,4 +6,4 @@", " 6", " 7", " 8", "-9", "+nine"),
+		},
+		{
+			name: "lines removed",
+			old:  lines("1", "2", "3", "4"),
+			new:  lines("1", "4"),
+			want: lines("--- live", "+++ proposed", "@@ -1,4 +1,2 @@", " 1", "-2", "-3", " 4"),
+		},
+		{
+			name: "everything added",
+			old:  "",
+			new:  lines("1", "2"),
+			want: lines("--- live", "+++ proposed", "@@ -0,0 +1,2 @@", "+1", "+2"),
+		},
+		{
+			name: "everything removed",
+			old:  lines("1", "2"),
+			new:  "",
+			want: lines("--- live", "+++ proposed", "@@ -1,2 +0,0 @@", "-1", "-2"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := unifiedDiff("live", "proposed", test.old, test.new); got != test.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	tests := []struct {
+		name string
+		old  map[string]interface{}
+		new  map[string]interface{}
+		want []manifestChange
+	}{
+		{
+			name: "no changes",
+			old:  map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(2)}},
+			new:  map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(2)}},
+			want: []manifestChange{},
+		},
+		{
+			name: "changed, added and removed keys are sorted by path",
+			old:  map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(2), "paused": true}},
+			new:  map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(3), "minReadySeconds": int64(5)}},
+			want: []manifestChange{
+				{Path: "spec.minReadySeconds", Op: "added", New: int64(5)},
+				{Path: "spec.paused", Op: "removed", Old: true},
+				{Path: "spec.replicas", Op: "changed", Old: int64(2), New: int64(3)},
+			},
+		},
+		{
+			name: "list items are compared by index",
+			old:  map[string]interface{}{"containers": []interface{}{map[string]interface{}{"image": "nginx:1.27"}}},
+			new:  map[string]interface{}{"containers": []interface{}{map[string]interface{}{"image": "nginx:1.28"}}},
+			want: []manifestChange{{Path: "containers[0].image", Op: "changed", Old: "nginx:1.27", New: "nginx:1.28"}},
+		},
+		{
+			name: "longer list",
+			old:  map[string]interface{}{"args": []interface{}{"a"}},
+			new:  map[string]interface{}{"args": []interface{}{"a", "b", "c"}},
+			want: []manifestChange{{Path: "args[1]", Op: "added", New: "b"}, {Path: "args[2]", Op: "added", New: "c"}},
+		},
+		{
+			name: "shorter list",
+			old:  map[string]interface{}{"args": []interface{}{"a", "b"}},
+			new:  map[string]interface{}{"args": []interface{}{"a"}},
+			want: []manifestChange{{Path: "args[1]", Op: "removed", Old: "b"}},
+		},
+		{
+			name: "a field that changes type is changed as a whole",
+			old:  map[string]interface{}{"value": map[string]interface{}{"a": "b"}},
+			new:  map[string]interface{}{"value": "b"},
+			want: []manifestChange{{Path: "value", Op: "changed", Old: map[string]interface{}{"a": "b"}, New: "b"}},
+		},
+		{
+			name: "a map that's added is reported once",
+			old:  map[string]interface{}{},
+			new:  map[string]interface{}{"labels": map[string]interface{}{"team": "payments"}},
+			want: []manifestChange{{Path: "labels", Op: "added", New: map[string]interface{}{"team": "payments"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := diffManifests("", test.old, test.new); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("diffManifests() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCleanDeploymentManifest(t *testing.T) {
+	spec := appsv1.DeploymentSpec{
+		Replicas: config.Int32Ptr(2),
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		Template: apiv1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+			Spec:       apiv1.PodSpec{Containers: []apiv1.Container{{Name: "web", Image: "nginx:1.27"}}},
+		},
+	}
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		wantAnnotations map[string]interface{}
+	}{
+		{
+			name: "only server annotations",
+			annotations: map[string]string{
+				"deployment.kubernetes.io/revision":                "3",
+				"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"apps/v1"}`,
+			},
+		},
+		{
+			name: "user annotations are kept",
+			annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"apps/v1"}`,
+				"team": "payments",
+			},
+			wantAnnotations: map[string]interface{}{"team": "payments"},
+		},
+		{
+			name: "no annotations",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "web",
+					Namespace:         "default",
+					Labels:            map[string]string{"app": "web"},
+					Annotations:       test.annotations,
+					UID:               "6a1c0a9e-1b7e-4c4e-9d7a-1f0c1e2d3b4a",
+					ResourceVersion:   "12345",
+					Generation:        4,
+					CreationTimestamp: metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+					ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply}},
+				},
+				Spec:   *spec.DeepCopy(),
+				Status: appsv1.DeploymentStatus{Replicas: 2, ReadyReplicas: 2, ObservedGeneration: 4},
+			}
+			original := deployment.DeepCopy()
+
+			manifest, err := cleanDeploymentManifest(deployment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(deployment, original) {
+				t.Error("the deployment passed in was changed")
+			}
+
+			if manifest["apiVersion"] != "apps/v1" || manifest["kind"] != "Deployment" {
+				t.Errorf("apiVersion, kind = %v, %v, want apps/v1, Deployment", manifest["apiVersion"], manifest["kind"])
+			}
+			if _, ok := manifest["status"]; ok {
+				t.Error("status wasn't removed")
+			}
+			for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields"} {
+				if _, ok, _ := unstructured.NestedFieldNoCopy(manifest, "metadata", field); ok {
+					t.Errorf("metadata.%s wasn't removed", field)
+				}
+			}
+			for _, field := range []string{"name", "namespace", "labels"} {
+				if _, ok, _ := unstructured.NestedFieldNoCopy(manifest, "metadata", field); !ok {
+					t.Errorf("metadata.%s was removed", field)
+				}
+			}
+			annotations, _, _ := unstructured.NestedMap(manifest, "metadata", "annotations")
+			if !reflect.DeepEqual(annotations, test.wantAnnotations) {
+				t.Errorf("annotations = %v, want %v", annotations, test.wantAnnotations)
+			}
+			if _, ok, _ := unstructured.NestedFieldNoCopy(manifest, "spec", "template", "metadata", "creationTimestamp"); ok {
+				t.Error("spec.template.metadata.creationTimestamp wasn't removed")
+			}
+
+			// The spec round trips back to the one the deployment had
+			cleanedSpec, _, _ := unstructured.NestedMap(manifest, "spec")
+			gotSpec := appsv1.DeploymentSpec{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(cleanedSpec, &gotSpec); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotSpec, spec) {
+				t.Errorf("spec = %+v, want %+v", gotSpec, spec)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The most the annotations of one object can add up to - the same limit the API server enforces
+const totalAnnotationSizeLimit = 256 * 1024
+
+// Pod labels that can't be set in a request - app and owner make up the deployment selector, and managed-by is set by this app
+// The Deployment's own labels aren't part of the selector, so these can be set there
+var reservedPodLabels = []string{"app", "owner", config.ManagedByLabel}
+
+// Apply the labels and annotations of a request to the deployment and its pod template
+// Provided keys are added to, or overwrite, the existing ones, so this is used by both CreateDeployment and UpdateDeployment
+func applyMetadata(deployment *appsv1.Deployment, createDeploymentStruct config.CreateDeploymentStruct) error {
+	if err := applyLabels("labels", &deployment.ObjectMeta, createDeploymentStruct.Labels, nil); err != nil {
+		return err
+	}
+	if err := applyAnnotations("annotations", &deployment.ObjectMeta, createDeploymentStruct.Annotations); err != nil {
+		return err
+	}
+	if err := applyLabels("podLabels", &deployment.Spec.Template.ObjectMeta, createDeploymentStruct.PodLabels, reservedPodLabels); err != nil {
+		return err
+	}
+
+	return applyAnnotations("podAnnotations", &deployment.Spec.Template.ObjectMeta, createDeploymentStruct.PodAnnotations)
+}
+
+func applyLabels(field string, objectMeta *metav1.ObjectMeta, labels map[string]string, reservedLabels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	if objectMeta.Labels == nil {
+		objectMeta.Labels = map[string]string{}
+	}
+	for key, value := range labels {
+		for _, reserved := range reservedLabels {
+			if key == reserved {
+				return newFieldError(field+"."+key, "is reserved and can't be set")
+			}
+		}
+		if err := validateLabel(field, key, value); err != nil {
+			return err
+		}
+		objectMeta.Labels[key] = value
+	}
+
+	return nil
+}
+
+func applyAnnotations(field string, objectMeta *metav1.ObjectMeta, annotations map[string]string) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+	if objectMeta.Annotations == nil {
+		objectMeta.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		// Annotation keys follow the same rules as label keys, but the values can be anything
+		if errs := validation.IsQualifiedName(strings.ToLower(key)); len(errs) > 0 {
+			return newFieldError(field, "key %q is invalid: %s", key, strings.Join(errs, ", "))
+		}
+		objectMeta.Annotations[key] = value
+	}
+	size := 0
+	for key, value := range objectMeta.Annotations {
+		size += len(key) + len(value)
+	}
+	if size > totalAnnotationSizeLimit {
+		return newFieldError(field, "annotations add up to %d bytes, which is more than the limit of %d", size, totalAnnotationSizeLimit)
+	}
+
+	return nil
+}
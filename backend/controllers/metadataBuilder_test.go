@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"testing"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestApplyMetadataReservedLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		request config.CreateDeploymentStruct
+		wantErr string
+	}{
+		{name: "ordinary labels", request: config.CreateDeploymentStruct{Labels: map[string]string{"team": "payments"}, PodLabels: map[string]string{"team": "payments"}}},
+		{name: "selector labels on the deployment", request: config.CreateDeploymentStruct{Labels: map[string]string{"app": "web", "owner": "payments"}}},
+		{name: "managed-by on the deployment", request: config.CreateDeploymentStruct{Labels: map[string]string{config.ManagedByLabel: "helm"}}},
+		{name: "app on the pods", request: config.CreateDeploymentStruct{PodLabels: map[string]string{"app": "other"}}, wantErr: "podLabels.app: is reserved and can't be set"},
+		{name: "owner on the pods", request: config.CreateDeploymentStruct{PodLabels: map[string]string{"owner": "other"}}, wantErr: "podLabels.owner: is reserved and can't be set"},
+		{name: "managed-by on the pods", request: config.CreateDeploymentStruct{PodLabels: map[string]string{config.ManagedByLabel: "helm"}}, wantErr: "podLabels." + config.ManagedByLabel + ": is reserved and can't be set"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			err := applyMetadata(deployment, test.request)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				for key, value := range test.request.Labels {
+					if deployment.Labels[key] != value {
+						t.Errorf("label %s = %q, want %q", key, deployment.Labels[key], value)
+					}
+				}
+				return
+			}
+			if err == nil || err.Error() != test.wantErr || errorStatus(err) != 400 {
+				t.Errorf("error = %v, want a 400 %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Set the liveness, readiness and startup probes of a container - field is the path of the container in the request
+// Probes that aren't provided are left as they are, unless healthPath is set, in which case liveness and readiness default to an HTTP GET on it
+func applyProbes(field string, container *apiv1.Container, containerStruct config.ContainerStruct) error {
+	var err error
+	if containerStruct.LivenessProbe != nil {
+		if container.LivenessProbe, err = buildProbe(field+"livenessProbe", container, *containerStruct.LivenessProbe, false); err != nil {
+			return err
+		}
+	} else if containerStruct.HealthPath != "" {
+		if container.LivenessProbe, err = defaultHealthProbe(field, container, containerStruct.HealthPath); err != nil {
+			return err
+		}
+	}
+	if containerStruct.ReadinessProbe != nil {
+		if container.ReadinessProbe, err = buildProbe(field+"readinessProbe", container, *containerStruct.ReadinessProbe, true); err != nil {
+			return err
+		}
+	} else if containerStruct.HealthPath != "" {
+		if container.ReadinessProbe, err = defaultHealthProbe(field, container, containerStruct.HealthPath); err != nil {
+			return err
+		}
+	}
+	if containerStruct.StartupProbe != nil {
+		if container.StartupProbe, err = buildProbe(field+"startupProbe", container, *containerStruct.StartupProbe, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check an init container doesn't set a probe - field is the path of the init container in the request
+// The API server rejects probes on init containers, which run to completion before the pod can be ready, so this includes the ones healthPath implies
+func validateInitContainerProbes(field string, containerStruct config.ContainerStruct) error {
+	switch {
+	case containerStruct.LivenessProbe != nil:
+		return newFieldError(field+"livenessProbe", "isn't supported on init containers")
+	case containerStruct.ReadinessProbe != nil:
+		return newFieldError(field+"readinessProbe", "isn't supported on init containers")
+	case containerStruct.StartupProbe != nil:
+		return newFieldError(field+"startupProbe", "isn't supported on init containers")
+	case containerStruct.HealthPath != "":
+		return newFieldError(field+"healthPath", "isn't supported on init containers")
+	}
+
+	return nil
+}
+
+// The HTTP GET probe used when only a healthPath is given - it targets the container's port with the Kubernetes default timings
+func defaultHealthProbe(field string, container *apiv1.Container, healthPath string) (*apiv1.Probe, error) {
+	if !strings.HasPrefix(healthPath, "/") {
+		return nil, newFieldError(field+"healthPath", "must start with /, got %q", healthPath)
+	}
+	if len(container.Ports) == 0 {
+		return nil, newFieldError(field+"healthPath", "requires a containerPort or ports to probe")
+	}
+
+	return buildProbe(field+"healthPath", container, config.ProbeStruct{Type: "httpGet", Path: healthPath}, true)
+}
+
+// Build a probe - allowSuccessThreshold is only true for readiness probes, the API server requires a successThreshold of 1 for the others
+func buildProbe(field string, container *apiv1.Container, probeStruct config.ProbeStruct, allowSuccessThreshold bool) (*apiv1.Probe, error) {
+	probe := &apiv1.Probe{}
+	switch probeStruct.Type {
+	case "httpGet":
+		port, err := probePort(field, container, probeStruct.Port, true)
+		if err != nil {
+			return nil, err
+		}
+		if probeStruct.Path != "" && !strings.HasPrefix(probeStruct.Path, "/") {
+			return nil, newFieldError(field+".path", "must start with /, got %q", probeStruct.Path)
+		}
+		scheme := apiv1.URIScheme(strings.ToUpper(probeStruct.Scheme))
+		if scheme != "" && scheme != apiv1.URISchemeHTTP && scheme != apiv1.URISchemeHTTPS {
+			return nil, newFieldError(field+".scheme", "must be either HTTP or HTTPS, got %q", probeStruct.Scheme)
+		}
+		probe.HTTPGet = &apiv1.HTTPGetAction{Path: probeStruct.Path, Port: port, Scheme: scheme}
+	case "tcpSocket":
+		port, err := probePort(field, container, probeStruct.Port, true)
+		if err != nil {
+			return nil, err
+		}
+		probe.TCPSocket = &apiv1.TCPSocketAction{Port: port}
+	case "exec":
+		if len(probeStruct.Command) == 0 {
+			return nil, newFieldError(field+".command", "is required for an exec probe")
+		}
+		probe.Exec = &apiv1.ExecAction{Command: probeStruct.Command}
+	case "grpc":
+		// gRPC probes only accept a port number
+		port, err := probePort(field, container, probeStruct.Port, false)
+		if err != nil {
+			return nil, err
+		}
+		probe.GRPC = &apiv1.GRPCAction{Port: port.IntVal}
+		if probeStruct.Service != "" {
+			probe.GRPC.Service = &probeStruct.Service
+		}
+	default:
+		return nil, newFieldError(field+".type", "must be one of httpGet, tcpSocket, exec or grpc, got %q", probeStruct.Type)
+	}
+
+	var err error
+	timings := []struct {
+		name  string
+		value string
+		min   int32
+		dest  *int32
+	}{
+		{"initialDelaySeconds", probeStruct.InitialDelaySeconds, 0, &probe.InitialDelaySeconds},
+		{"periodSeconds", probeStruct.PeriodSeconds, 1, &probe.PeriodSeconds},
+		{"timeoutSeconds", probeStruct.TimeoutSeconds, 1, &probe.TimeoutSeconds},
+		{"successThreshold", probeStruct.SuccessThreshold, 1, &probe.SuccessThreshold},
+		{"failureThreshold", probeStruct.FailureThreshold, 1, &probe.FailureThreshold},
+	}
+	for _, timing := range timings {
+		var value *int32
+		if value, err = parseOptionalInt32Field(field+"."+timing.name, timing.value, timing.min); err != nil {
+			return nil, err
+		}
+		if value != nil {
+			*timing.dest = *value
+		}
+	}
+	if !allowSuccessThreshold && probe.SuccessThreshold > 1 {
+		return nil, newFieldError(field+".successThreshold", "must be 1 for liveness and startup probes")
+	}
+
+	return probe, nil
+}
+
+// Get the port a probe targets - a number, or a port name if allowNames is set
+// When no port is given the container's port named "http" is used, which is the one created from containerPort, or else its first port
+func probePort(field string, container *apiv1.Container, port string, allowNames bool) (intstr.IntOrString, error) {
+	if port == "" {
+		for _, containerPort := range container.Ports {
+			if containerPort.Name == "http" {
+				return intstr.FromInt32(containerPort.ContainerPort), nil
+			}
+		}
+		if len(container.Ports) > 0 {
+			return intstr.FromInt32(container.Ports[0].ContainerPort), nil
+		}
+		return intstr.IntOrString{}, newFieldError(field+".port", "is required when the container has no containerPort or ports")
+	}
+
+	if number, err := strconv.ParseInt(port, 10, 32); err == nil {
+		if errs := validation.IsValidPortNum(int(number)); len(errs) > 0 {
+			return intstr.IntOrString{}, newFieldError(field+".port", "%s", strings.Join(errs, ", "))
+		}
+		return intstr.FromInt32(int32(number)), nil
+	}
+	if !allowNames {
+		return intstr.IntOrString{}, newFieldError(field+".port", "must be a port number, got %q", port)
+	}
+	if errs := validation.IsValidPortName(port); len(errs) > 0 {
+		return intstr.IntOrString{}, newFieldError(field+".port", "%q is invalid: %s", port, strings.Join(errs, ", "))
+	}
+
+	return intstr.FromString(port), nil
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Annotation the deployment controller sets on a deployment and its ReplicaSets with the rollout revision
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+	// Annotation recording why a change was made - shown as CHANGE-CAUSE by `kubectl rollout history`
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// A revision of a deployment, backed by one of its ReplicaSets
+type deploymentRevision struct {
+	Revision    int64       `json:"revision"`
+	ReplicaSet  string      `json:"replicaSet"`
+	Images      []string    `json:"images"`
+	ChangeCause string      `json:"changeCause"`
+	Replicas    int32       `json:"replicas"`
+	Current     bool        `json:"current"`
+	CreatedAt   metav1.Time `json:"createdAt"`
+	replicaSet  *appsv1.ReplicaSet
+}
+
+// List the rollout history of a deployment - the same as `kubectl rollout history`
+func RolloutHistory(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+
+	deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	revisions, err := listDeploymentRevisions(cluster.Clientset, deployment)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// Log the revisions
+	for _, r := range revisions {
+		zap.L().Info(" * " + strconv.FormatInt(r.Revision, 10) + " - " + r.ReplicaSet)
+	}
+
+	return c.JSON(fiber.Map{"revisions": revisions})
+}
+
+// List the revisions of a deployment, sorted from oldest to newest
+// Each revision is a ReplicaSet controlled by the deployment, with the revision number in its deployment.kubernetes.io/revision annotation
+func listDeploymentRevisions(clientset kubernetes.Interface, deployment *appsv1.Deployment) ([]deploymentRevision, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := clientset.AppsV1().ReplicaSets(deployment.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []deploymentRevision{}
+	for i := range replicaSets.Items {
+		replicaSet := &replicaSets.Items[i]
+		// Another deployment could have an overlapping selector - only ReplicaSets controlled by this deployment are part of its history
+		if !metav1.IsControlledBy(replicaSet, deployment) {
+			continue
+		}
+		revision, err := strconv.ParseInt(replicaSet.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			zap.L().Warn("Skipping ReplicaSet " + replicaSet.Name + " with an invalid revision: " + err.Error())
+			continue
+		}
+
+		images := []string{}
+		for _, container := range replicaSet.Spec.Template.Spec.Containers {
+			images = append(images, container.Image)
+		}
+		revisions = append(revisions, deploymentRevision{
+			Revision:    revision,
+			ReplicaSet:  replicaSet.Name,
+			Images:      images,
+			ChangeCause: replicaSet.Annotations[changeCauseAnnotation],
+			Replicas:    replicaSet.Status.Replicas,
+			Current:     replicaSet.Annotations[revisionAnnotation] == deployment.Annotations[revisionAnnotation],
+			CreatedAt:   replicaSet.CreationTimestamp,
+			replicaSet:  replicaSet,
+		})
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+
+	return revisions, nil
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Pause the rollout of a deployment - the same as `kubectl rollout pause`
+// Changes to a paused deployment's pod template aren't rolled out until it's resumed
+func RolloutPause(c *fiber.Ctx) error {
+	return setDeploymentPaused(c, true)
+}
+
+// Resume the rollout of a paused deployment - the same as `kubectl rollout resume`
+func RolloutResume(c *fiber.Ctx) error {
+	return setDeploymentPaused(c, false)
+}
+
+func setDeploymentPaused(c *fiber.Ctx, paused bool) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	action := "Paused"
+	if !paused {
+		action = "Resumed"
+	}
+
+	deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// Nothing to do if the deployment is already in the requested state
+	if deployment.Spec.Paused == paused {
+		zap.L().Info("Deployment " + deploymentName + " is already " + strings.ToLower(action))
+		return c.JSON(fiber.Map{"message": "Deployment " + deploymentName + " is already " + strings.ToLower(action), "deployment": deployment})
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"paused": paused},
+	})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := cluster.Clientset.AppsV1().Deployments(namespace).Patch(context.TODO(), deploymentName, types.StrategicMergePatchType, patch, metav1.PatchOptions{DryRun: dryRun})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	zap.L().Info(dryRunMessage(dryRun, action+" deployment "+deploymentName))
+	if len(dryRun) == 0 {
+		waitForCache(func() bool {
+			deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+			return err == nil && deployment.GetResourceVersion() == result.GetResourceVersion()
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": dryRunMessage(dryRun, action+" deployment "+deploymentName), "deployment": result})
+}
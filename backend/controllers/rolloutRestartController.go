@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Pod template annotation stamped by `kubectl rollout restart` - changing it rolls out new pods
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// Restart a deployment - the same as `kubectl rollout restart`
+// Stamping the current time on the pod template makes the deployment roll out new pods using its strategy, instead of deleting them one by one
+func RolloutRestart(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// The deployment controller doesn't roll out changes to a paused deployment
+	if deployment.Spec.Paused {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment " + deploymentName + " is paused - resume it before restarting"})
+	}
+
+	restartedAt := time.Now().Format(time.RFC3339)
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{restartedAtAnnotation: restartedAt},
+				},
+			},
+		},
+	})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	zap.L().Info("Restarting deployment " + deploymentName)
+	result, err := cluster.Clientset.AppsV1().Deployments(namespace).Patch(context.TODO(), deploymentName, types.StrategicMergePatchType, patch, metav1.PatchOptions{DryRun: dryRun})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	zap.L().Info(dryRunMessage(dryRun, "Restarted deployment "+deploymentName+" at "+restartedAt))
+	if len(dryRun) == 0 {
+		waitForCache(func() bool {
+			deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+			return err == nil && deployment.GetResourceVersion() == result.GetResourceVersion()
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": dryRunMessage(dryRun, "Restarted deployment "+deploymentName), "deployment": result})
+}
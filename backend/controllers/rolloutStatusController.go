@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+const (
+	// The longest a rollout status stream is kept open for - a rollout that is still progressing after this is left to the client to check again
+	rolloutStatusTimeout = 30 * time.Minute
+	// How often a comment is sent to keep the stream open through proxies, and to notice clients that have disconnected
+	rolloutStatusHeartbeat = 15 * time.Second
+)
+
+// The rollout status of a deployment, sent as the data of each event on the stream
+type rolloutStatus struct {
+	Message            string                       `json:"message"`
+	Done               bool                         `json:"done"`
+	Failed             bool                         `json:"failed"`
+	Generation         int64                        `json:"generation"`
+	ObservedGeneration int64                        `json:"observedGeneration"`
+	Replicas           int32                        `json:"replicas"`
+	UpdatedReplicas    int32                        `json:"updatedReplicas"`
+	ReadyReplicas      int32                        `json:"readyReplicas"`
+	AvailableReplicas  int32                        `json:"availableReplicas"`
+	Conditions         []appsv1.DeploymentCondition `json:"conditions"`
+}
+
+// Stream the rollout status of a deployment as server-sent events until the rollout completes or fails
+// This follows the same rules as `kubectl rollout status`
+// Each change to the status is sent as a "status" event, and the stream ends with a "complete" or "failed" event
+func RolloutStatus(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+
+	// Get the deployment before starting the stream so a missing deployment is returned as a normal error response
+	// The resourceVersion is used to start watching from this point onwards
+	deploymentsClient := cluster.Clientset.AppsV1().Deployments(namespace)
+	deployment, err := deploymentsClient.Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// The retry watcher re-establishes the watch if it is closed by the API server part way through a rollout
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", deploymentName).String()
+	watcher, err := watchtools.NewRetryWatcher(deployment.GetResourceVersion(), &cache.ListWatch{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return deploymentsClient.Watch(context.TODO(), options)
+		},
+	})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The fiber context can't be used once the handler returns, so everything the stream needs is captured beforehand
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer watcher.Stop()
+		zap.L().Info("Streaming rollout status for deployment: " + deploymentName)
+
+		timeout := time.NewTimer(rolloutStatusTimeout)
+		defer timeout.Stop()
+		heartbeat := time.NewTicker(rolloutStatusHeartbeat)
+		defer heartbeat.Stop()
+
+		// Send a status, and return whether the stream carries on - it ends once the rollout completes or fails, or the client disconnects
+		send := func(status rolloutStatus) bool {
+			if err := writeRolloutStatus(w, status); err != nil {
+				zap.L().Info("Client disconnected from the rollout status stream for deployment: " + deploymentName)
+				return false
+			}
+			if status.Done || status.Failed {
+				zap.L().Info("Rollout status stream for deployment: " + deploymentName + " ended - " + status.Message)
+				return false
+			}
+			return true
+		}
+
+		status := getRolloutStatus(deployment)
+		if !send(status) {
+			return
+		}
+		for {
+			select {
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					writeRolloutStatus(w, rolloutStatus{Message: "The watch on deployment " + deploymentName + " was closed", Failed: true})
+					return
+				}
+				switch event.Type {
+				case watch.Deleted:
+					writeRolloutStatus(w, rolloutStatus{Message: "Deployment " + deploymentName + " was deleted", Failed: true})
+					return
+				case watch.Error:
+					writeRolloutStatus(w, rolloutStatus{Message: fmt.Sprintf("Error watching deployment %s: %v", deploymentName, event.Object), Failed: true})
+					return
+				case watch.Added, watch.Modified:
+					// A deployment is modified for reasons that don't change its rollout status, e.g. an annotation - only changes are sent
+					if d, ok := event.Object.(*appsv1.Deployment); ok {
+						if next := getRolloutStatus(d); !reflect.DeepEqual(next, status) {
+							status = next
+							if !send(status) {
+								return
+							}
+						}
+					}
+				}
+			case <-heartbeat.C:
+				// SSE comment lines are ignored by clients
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil || w.Flush() != nil {
+					zap.L().Info("Client disconnected from the rollout status stream for deployment: " + deploymentName)
+					return
+				}
+			case <-timeout.C:
+				writeRolloutStatus(w, rolloutStatus{Message: "Timed out after " + rolloutStatusTimeout.String() + " waiting for the rollout of deployment " + deploymentName, Failed: true})
+				return
+			}
+		}
+	}))
+
+	return nil
+}
+
+// Work out the rollout status of a deployment - this is the same logic `kubectl rollout status` uses
+func getRolloutStatus(deployment *appsv1.Deployment) rolloutStatus {
+	status := rolloutStatus{
+		Generation:         deployment.Generation,
+		ObservedGeneration: deployment.Status.ObservedGeneration,
+		Replicas:           deployment.Status.Replicas,
+		UpdatedReplicas:    deployment.Status.UpdatedReplicas,
+		ReadyReplicas:      deployment.Status.ReadyReplicas,
+		AvailableReplicas:  deployment.Status.AvailableReplicas,
+		Conditions:         []appsv1.DeploymentCondition{},
+	}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing || condition.Type == appsv1.DeploymentAvailable {
+			status.Conditions = append(status.Conditions, condition)
+		}
+	}
+
+	// The deployment controller hasn't seen the latest spec yet
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		status.Message = "Waiting for deployment spec update to be observed..."
+		return status
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == apiv1.ConditionFalse && condition.Reason == "ProgressDeadlineExceeded" {
+			status.Message = fmt.Sprintf("Deployment %q exceeded its progress deadline", deployment.Name)
+			status.Failed = true
+			return status
+		}
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	switch {
+	case deployment.Status.UpdatedReplicas < replicas:
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...", deployment.Name, deployment.Status.UpdatedReplicas, replicas)
+	case deployment.Status.Replicas > deployment.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...", deployment.Name, deployment.Status.Replicas-deployment.Status.UpdatedReplicas)
+	case deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...", deployment.Name, deployment.Status.AvailableReplicas, deployment.Status.UpdatedReplicas)
+	default:
+		status.Message = fmt.Sprintf("Deployment %q successfully rolled out", deployment.Name)
+		status.Done = true
+	}
+
+	return status
+}
+
+// Write a rollout status to the stream as a server-sent event
+func writeRolloutStatus(w *bufio.Writer, status rolloutStatus) error {
+	event := "status"
+	if status.Done {
+		event = "complete"
+	} else if status.Failed {
+		event = "failed"
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
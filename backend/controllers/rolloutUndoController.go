@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Roll a deployment back to a previous revision - the same as `kubectl rollout undo`
+// The pod template of the chosen revision's ReplicaSet is restored on the deployment, which starts a new rollout
+func RolloutUndo(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var rollbackDeploymentStruct = config.RollbackDeploymentStruct{}
+	// The body is optional - without one the deployment is rolled back to the previous revision
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&rollbackDeploymentStruct); err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	var toRevision int64
+	if rollbackDeploymentStruct.Revision != "" {
+		if toRevision, err = strconv.ParseInt(rollbackDeploymentStruct.Revision, 10, 64); err != nil || toRevision < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "revision must be a positive number"})
+		}
+	}
+
+	deploymentsClient := cluster.Clientset.AppsV1().Deployments(namespace)
+	var result *appsv1.Deployment
+	var rolledBackTo int64
+	skipped := false
+	// If the deployment is updated by someone else in between, the update fails with a conflict and is retried against the newer version
+	rollbackErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment, err := deploymentsClient.Get(context.TODO(), deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		// The deployment controller doesn't roll out changes to a paused deployment
+		if deployment.Spec.Paused {
+			return apierrors.NewBadRequest("Deployment " + deploymentName + " is paused - resume it before rolling back")
+		}
+
+		revisions, err := listDeploymentRevisions(cluster.Clientset, deployment)
+		if err != nil {
+			return err
+		}
+		revision, err := findRollbackRevision(revisions, deployment, toRevision)
+		if err != nil {
+			return err
+		}
+		rolledBackTo = revision.Revision
+
+		// The pod-template-hash label is added to the ReplicaSet by the deployment controller and isn't part of the deployment's template
+		template := revision.replicaSet.Spec.Template.DeepCopy()
+		delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+		if equality.Semantic.DeepEqual(template, &deployment.Spec.Template) {
+			zap.L().Info("Deployment " + deploymentName + " already matches revision " + fmt.Sprint(revision.Revision) + ", skipping rollback")
+			result = deployment
+			skipped = true
+			return nil
+		}
+
+		deployment.Spec.Template = *template
+		// Carry the change cause over so the history shows why the revision was originally made
+		if changeCause, ok := revision.replicaSet.Annotations[changeCauseAnnotation]; ok {
+			if deployment.Annotations == nil {
+				deployment.Annotations = map[string]string{}
+			}
+			deployment.Annotations[changeCauseAnnotation] = changeCause
+		} else {
+			delete(deployment.Annotations, changeCauseAnnotation)
+		}
+
+		zap.L().Info("Rolling back deployment " + deploymentName + " to revision " + fmt.Sprint(revision.Revision))
+		result, err = deploymentsClient.Update(context.TODO(), deployment, metav1.UpdateOptions{DryRun: dryRun})
+		return err
+	})
+	if rollbackErr != nil {
+		zap.L().Error(rollbackErr.Error())
+		return c.Status(errorStatus(rollbackErr)).JSON(fiber.Map{"error": rollbackErr.Error()})
+	}
+	if skipped {
+		return c.JSON(fiber.Map{"message": "Skipped rollback of deployment " + deploymentName + " - it already matches revision " + fmt.Sprint(rolledBackTo), "deployment": result})
+	}
+
+	zap.L().Info(dryRunMessage(dryRun, "Rolled back deployment "+deploymentName+" to revision "+fmt.Sprint(rolledBackTo)))
+	if len(dryRun) == 0 {
+		waitForCache(func() bool {
+			deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+			return err == nil && deployment.GetResourceVersion() == result.GetResourceVersion()
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": dryRunMessage(dryRun, "Rolled back deployment "+deploymentName+" to revision "+fmt.Sprint(rolledBackTo)), "deployment": result})
+}
+
+// Find the revision to roll back to - a revision of 0 means the newest revision before the current one
+func findRollbackRevision(revisions []deploymentRevision, deployment *appsv1.Deployment, toRevision int64) (*deploymentRevision, error) {
+	if toRevision > 0 {
+		for i := range revisions {
+			if revisions[i].Revision == toRevision {
+				return &revisions[i], nil
+			}
+		}
+		return nil, apierrors.NewNotFound(appsv1.Resource("replicasets"), fmt.Sprintf("revision %d of deployment %s", toRevision, deployment.Name))
+	}
+
+	// Revisions are sorted oldest to newest, so the previous revision is the last one that isn't current
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if !revisions[i].Current {
+			return &revisions[i], nil
+		}
+	}
+
+	return nil, apierrors.NewBadRequest("Deployment " + deployment.Name + " has no previous revision to roll back to")
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Scale a deployment through the scale subresource
+func ScaleDeployment(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var scaleDeploymentStruct = config.ScaleDeploymentStruct{}
+	// Parse the request body into the scaleDeploymentStruct struct
+	if err := c.BodyParser(&scaleDeploymentStruct); err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	// A leading + or - means the replica count is relative to the current count
+	replicas := strings.TrimSpace(scaleDeploymentStruct.Replicas)
+	relative := strings.HasPrefix(replicas, "+") || strings.HasPrefix(replicas, "-")
+	replicaCount, err := strconv.ParseInt(replicas, 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "replicas must be a number, or a relative change such as +2 or -1"})
+	}
+	zap.L().Info("User provided replicas: " + replicas)
+
+	deploymentsClient := cluster.Clientset.AppsV1().Deployments(namespace)
+	var result *autoscalingv1.Scale
+	// If the deployment is scaled by someone else in between, the update fails with a conflict and is retried against the newer scale
+	scaleErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, err := deploymentsClient.GetScale(context.TODO(), deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		desired := int32(replicaCount)
+		if relative {
+			desired = scale.Spec.Replicas + int32(replicaCount)
+		}
+		if desired < 0 {
+			return apierrors.NewBadRequest(fmt.Sprintf("Can't scale deployment %s from %d to %d replicas", deploymentName, scale.Spec.Replicas, desired))
+		}
+
+		zap.L().Info("Scaling deployment " + deploymentName + " from " + fmt.Sprint(scale.Spec.Replicas) + " to " + fmt.Sprint(desired) + " replicas")
+		scale.Spec.Replicas = desired
+		result, err = deploymentsClient.UpdateScale(context.TODO(), deploymentName, scale, metav1.UpdateOptions{DryRun: dryRun})
+		return err
+	})
+	if scaleErr != nil {
+		zap.L().Error(scaleErr.Error())
+		return c.Status(errorStatus(scaleErr)).JSON(fiber.Map{"error": scaleErr.Error()})
+	}
+
+	// The scale subresource has no ready count - get it from the deployment status
+	deployment, err := deploymentsClient.Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	zap.L().Info(dryRunMessage(dryRun, "Scaled deployment "+deploymentName+" to "+fmt.Sprint(result.Spec.Replicas)+" replicas"))
+	if len(dryRun) == 0 {
+		waitForCache(func() bool {
+			deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(deploymentName)
+			return err == nil && deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == result.Spec.Replicas
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": dryRunMessage(dryRun, "Scaled deployment "+deploymentName+" to "+fmt.Sprint(result.Spec.Replicas)+" replicas"),
+		"desired": result.Spec.Replicas,
+		"current": result.Status.Replicas,
+		"ready":   deployment.Status.ReadyReplicas,
+	})
+}
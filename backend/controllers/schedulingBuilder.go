@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Apply the scheduling fields of a request to a deployment's pod spec
+// Each field is replaced as a whole when provided, so this is used by both CreateDeployment and UpdateDeployment
+func applyScheduling(deployment *appsv1.Deployment, createDeploymentStruct config.CreateDeploymentStruct) error {
+	podSpec := &deployment.Spec.Template.Spec
+	if createDeploymentStruct.NodeSelector != nil {
+		for key, value := range createDeploymentStruct.NodeSelector {
+			if err := validateLabel("nodeSelector", key, value); err != nil {
+				return err
+			}
+		}
+		podSpec.NodeSelector = createDeploymentStruct.NodeSelector
+	}
+	if createDeploymentStruct.Tolerations != nil {
+		if err := validateTolerations(createDeploymentStruct.Tolerations); err != nil {
+			return err
+		}
+		podSpec.Tolerations = createDeploymentStruct.Tolerations
+	}
+	if createDeploymentStruct.Affinity != nil {
+		if err := validateAffinity(createDeploymentStruct.Affinity); err != nil {
+			return err
+		}
+		podSpec.Affinity = createDeploymentStruct.Affinity
+	}
+	if createDeploymentStruct.TopologySpreadConstraints != nil {
+		constraints := createDeploymentStruct.TopologySpreadConstraints
+		for i := range constraints {
+			field := fmt.Sprintf("topologySpreadConstraints[%d]", i)
+			if constraints[i].MaxSkew < 1 {
+				return newFieldError(field+".maxSkew", "must be at least 1, got %d", constraints[i].MaxSkew)
+			}
+			if constraints[i].TopologyKey == "" {
+				return newFieldError(field+".topologyKey", "is required, e.g. topology.kubernetes.io/zone")
+			}
+			switch constraints[i].WhenUnsatisfiable {
+			case apiv1.DoNotSchedule:
+			case apiv1.ScheduleAnyway:
+				if constraints[i].MinDomains != nil {
+					return newFieldError(field+".minDomains", "can only be set when whenUnsatisfiable is DoNotSchedule")
+				}
+			default:
+				return newFieldError(field+".whenUnsatisfiable", "must be either DoNotSchedule or ScheduleAnyway, got %q", constraints[i].WhenUnsatisfiable)
+			}
+			// Without a label selector the constraint spreads the deployment's own pods
+			if constraints[i].LabelSelector == nil {
+				constraints[i].LabelSelector = deployment.Spec.Selector.DeepCopy()
+			}
+		}
+		podSpec.TopologySpreadConstraints = constraints
+	}
+	if createDeploymentStruct.PriorityClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(createDeploymentStruct.PriorityClassName); len(errs) > 0 {
+			return newFieldError("priorityClassName", "%q is invalid: %s", createDeploymentStruct.PriorityClassName, strings.Join(errs, ", "))
+		}
+		podSpec.PriorityClassName = createDeploymentStruct.PriorityClassName
+	}
+
+	return nil
+}
+
+// Validate a label key and value - field is the map the label belongs to
+func validateLabel(field string, key string, value string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return newFieldError(field, "key %q is invalid: %s", key, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return newFieldError(field+"."+key, "value %q is invalid: %s", value, strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// Validate the tolerations of a pod - the same rules the API server uses, returned as a 400 naming the field
+func validateTolerations(tolerations []apiv1.Toleration) error {
+	for i, toleration := range tolerations {
+		field := fmt.Sprintf("tolerations[%d]", i)
+		if toleration.Key != "" {
+			if errs := validation.IsQualifiedName(toleration.Key); len(errs) > 0 {
+				return newFieldError(field+".key", "%q is invalid: %s", toleration.Key, strings.Join(errs, ", "))
+			}
+		}
+		switch toleration.Operator {
+		case apiv1.TolerationOpExists:
+			if toleration.Value != "" {
+				return newFieldError(field+".value", "must be empty when operator is Exists")
+			}
+		case apiv1.TolerationOpEqual, "":
+			// An empty key matches every taint, which only makes sense with Exists
+			if toleration.Key == "" {
+				return newFieldError(field+".operator", "must be Exists when key is empty")
+			}
+		default:
+			return newFieldError(field+".operator", "must be either Equal or Exists, got %q", toleration.Operator)
+		}
+		switch toleration.Effect {
+		case "", apiv1.TaintEffectNoSchedule, apiv1.TaintEffectPreferNoSchedule, apiv1.TaintEffectNoExecute:
+		default:
+			return newFieldError(field+".effect", "must be one of NoSchedule, PreferNoSchedule or NoExecute, got %q", toleration.Effect)
+		}
+		if toleration.TolerationSeconds != nil && toleration.Effect != apiv1.TaintEffectNoExecute {
+			return newFieldError(field+".tolerationSeconds", "can only be set when effect is NoExecute")
+		}
+	}
+
+	return nil
+}
+
+// Validate the parts of an affinity that are easy to get wrong - preferred term weights and pod affinity topology keys
+func validateAffinity(affinity *apiv1.Affinity) error {
+	if nodeAffinity := affinity.NodeAffinity; nodeAffinity != nil {
+		if required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil && len(required.NodeSelectorTerms) == 0 {
+			return newFieldError("affinity.nodeAffinity.requiredDuringSchedulingIgnoredDuringExecution.nodeSelectorTerms", "at least one term is required")
+		}
+		for i, term := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			if err := validateWeight(fmt.Sprintf("affinity.nodeAffinity.preferredDuringSchedulingIgnoredDuringExecution[%d].weight", i), term.Weight); err != nil {
+				return err
+			}
+		}
+	}
+	if affinity.PodAffinity != nil {
+		if err := validatePodAffinityTerms("affinity.podAffinity", affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution); err != nil {
+			return err
+		}
+	}
+	if affinity.PodAntiAffinity != nil {
+		if err := validatePodAffinityTerms("affinity.podAntiAffinity", affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validatePodAffinityTerms(field string, required []apiv1.PodAffinityTerm, preferred []apiv1.WeightedPodAffinityTerm) error {
+	for i, term := range required {
+		if term.TopologyKey == "" {
+			return newFieldError(fmt.Sprintf("%s.requiredDuringSchedulingIgnoredDuringExecution[%d].topologyKey", field, i), "is required, e.g. kubernetes.io/hostname")
+		}
+	}
+	for i, term := range preferred {
+		termField := fmt.Sprintf("%s.preferredDuringSchedulingIgnoredDuringExecution[%d]", field, i)
+		if err := validateWeight(termField+".weight", term.Weight); err != nil {
+			return err
+		}
+		if term.PodAffinityTerm.TopologyKey == "" {
+			return newFieldError(termField+".podAffinityTerm.topologyKey", "is required, e.g. kubernetes.io/hostname")
+		}
+	}
+
+	return nil
+}
+
+func validateWeight(field string, weight int32) error {
+	if weight < 1 || weight > 100 {
+		return newFieldError(field, "must be between 1 and 100, got %d", weight)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Apply the security settings of a request to the pod and every container, including init containers
+// The containers' security contexts are replaced as a whole, so this is used by both CreateDeployment and UpdateDeployment
+func applySecurityContext(podSpec *apiv1.PodSpec, securityContextStruct *config.SecurityContextStruct) error {
+	if securityContextStruct == nil {
+		return nil
+	}
+
+	settings := *securityContextStruct
+	switch settings.Preset {
+	case "":
+	case "restricted":
+		// Fill in what the restricted Pod Security Standard requires, without overriding fields that are set explicitly
+		if settings.RunAsNonRoot == nil {
+			settings.RunAsNonRoot = config.BoolPtr(true)
+		}
+		if settings.AllowPrivilegeEscalation == nil {
+			settings.AllowPrivilegeEscalation = config.BoolPtr(false)
+		}
+		if settings.DropCapabilities == nil {
+			settings.DropCapabilities = []string{"ALL"}
+		}
+		if settings.SeccompProfile == "" {
+			settings.SeccompProfile = string(apiv1.SeccompProfileTypeRuntimeDefault)
+		}
+		if err := validateRestricted(podSpec, settings); err != nil {
+			return err
+		}
+	default:
+		return newFieldError("securityContext.preset", "must be restricted, got %q", settings.Preset)
+	}
+
+	podSecurityContext := &apiv1.PodSecurityContext{RunAsNonRoot: settings.RunAsNonRoot}
+	ids := []struct {
+		name  string
+		value string
+		dest  **int64
+	}{
+		{"runAsUser", settings.RunAsUser, &podSecurityContext.RunAsUser},
+		{"runAsGroup", settings.RunAsGroup, &podSecurityContext.RunAsGroup},
+		{"fsGroup", settings.FSGroup, &podSecurityContext.FSGroup},
+	}
+	for _, id := range ids {
+		value, err := parseOptionalInt32Field("securityContext."+id.name, id.value, 0)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			*id.dest = config.Int64Ptr(int64(*value))
+		}
+	}
+	// The kubelet refuses to start a container that runs as root when runAsNonRoot is set, so catch it here instead
+	if podSecurityContext.RunAsUser != nil && *podSecurityContext.RunAsUser == 0 && settings.RunAsNonRoot != nil && *settings.RunAsNonRoot {
+		return newFieldError("securityContext.runAsUser", "can't be 0 when runAsNonRoot is set")
+	}
+
+	switch apiv1.SeccompProfileType(settings.SeccompProfile) {
+	case "":
+		if settings.SeccompLocalhostProfile != "" {
+			return newFieldError("securityContext.seccompLocalhostProfile", "can only be set when seccompProfile is Localhost")
+		}
+	case apiv1.SeccompProfileTypeRuntimeDefault, apiv1.SeccompProfileTypeUnconfined:
+		if settings.SeccompLocalhostProfile != "" {
+			return newFieldError("securityContext.seccompLocalhostProfile", "can only be set when seccompProfile is Localhost")
+		}
+		podSecurityContext.SeccompProfile = &apiv1.SeccompProfile{Type: apiv1.SeccompProfileType(settings.SeccompProfile)}
+	case apiv1.SeccompProfileTypeLocalhost:
+		if settings.SeccompLocalhostProfile == "" {
+			return newFieldError("securityContext.seccompLocalhostProfile", "is required when seccompProfile is Localhost")
+		}
+		podSecurityContext.SeccompProfile = &apiv1.SeccompProfile{Type: apiv1.SeccompProfileTypeLocalhost, LocalhostProfile: &settings.SeccompLocalhostProfile}
+	default:
+		return newFieldError("securityContext.seccompProfile", "must be one of RuntimeDefault, Localhost or Unconfined, got %q", settings.SeccompProfile)
+	}
+
+	capabilities := []apiv1.Capability{}
+	for i, capability := range settings.DropCapabilities {
+		if capability == "" || strings.ContainsAny(capability, " \t") {
+			return newFieldError(fmt.Sprintf("securityContext.dropCapabilities[%d]", i), "%q is not a capability, e.g. ALL or NET_RAW", capability)
+		}
+		capabilities = append(capabilities, apiv1.Capability(strings.ToUpper(capability)))
+	}
+
+	podSpec.SecurityContext = podSecurityContext
+	// The user, group and seccomp settings are inherited from the pod - the rest can only be set on containers
+	containerSecurityContext := apiv1.SecurityContext{
+		RunAsNonRoot:             settings.RunAsNonRoot,
+		ReadOnlyRootFilesystem:   settings.ReadOnlyRootFilesystem,
+		AllowPrivilegeEscalation: settings.AllowPrivilegeEscalation,
+	}
+	if len(capabilities) > 0 {
+		containerSecurityContext.Capabilities = &apiv1.Capabilities{Drop: capabilities}
+	}
+	for i := range podSpec.InitContainers {
+		podSpec.InitContainers[i].SecurityContext = containerSecurityContext.DeepCopy()
+	}
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].SecurityContext = containerSecurityContext.DeepCopy()
+	}
+
+	return nil
+}
+
+// Check that explicit fields don't undo the restricted preset - otherwise the deployment would still be rejected by the namespace's policy
+// The containers are checked too, so this runs after they're built
+func validateRestricted(podSpec *apiv1.PodSpec, settings config.SecurityContextStruct) error {
+	if !*settings.RunAsNonRoot {
+		return newFieldError("securityContext.runAsNonRoot", "must be true with the restricted preset")
+	}
+	if *settings.AllowPrivilegeEscalation {
+		return newFieldError("securityContext.allowPrivilegeEscalation", "must be false with the restricted preset")
+	}
+	dropsAll := false
+	for _, capability := range settings.DropCapabilities {
+		if strings.EqualFold(capability, "ALL") {
+			dropsAll = true
+		}
+	}
+	if !dropsAll {
+		return newFieldError("securityContext.dropCapabilities", "must include ALL with the restricted preset")
+	}
+	if settings.SeccompProfile == string(apiv1.SeccompProfileTypeUnconfined) {
+		return newFieldError("securityContext.seccompProfile", "can't be Unconfined with the restricted preset")
+	}
+	// Host ports aren't allowed by the restricted (or baseline) Pod Security Standard
+	for _, container := range append(append([]apiv1.Container{}, podSpec.InitContainers...), podSpec.Containers...) {
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				return newFieldError("securityContext.preset", "restricted doesn't allow a hostPort, container %s sets %d on port %s", container.Name, port.HostPort, port.Name)
+			}
+		}
+	}
+
+	return nil
+}
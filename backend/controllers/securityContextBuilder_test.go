@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"testing"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestApplySecurityContextRestrictedHostPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		podSpec apiv1.PodSpec
+		wantErr string
+	}{
+		{
+			name:    "no host ports",
+			podSpec: apiv1.PodSpec{Containers: []apiv1.Container{{Name: "web", Ports: []apiv1.ContainerPort{{Name: "http", ContainerPort: 8080}}}}},
+		},
+		{
+			name:    "container host port",
+			podSpec: apiv1.PodSpec{Containers: []apiv1.Container{{Name: "web", Ports: []apiv1.ContainerPort{{Name: "http", ContainerPort: 8080, HostPort: 80}}}}},
+			wantErr: "securityContext.preset: restricted doesn't allow a hostPort, container web sets 80 on port http",
+		},
+		{
+			name: "init container host port",
+			podSpec: apiv1.PodSpec{
+				InitContainers: []apiv1.Container{{Name: "setup", Ports: []apiv1.ContainerPort{{Name: "setup", ContainerPort: 9000, HostPort: 9000}}}},
+				Containers:     []apiv1.Container{{Name: "web"}},
+			},
+			wantErr: "securityContext.preset: restricted doesn't allow a hostPort, container setup sets 9000 on port setup",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := applySecurityContext(&test.podSpec, &config.SecurityContextStruct{Preset: "restricted"})
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.wantErr || errorStatus(err) != 400 {
+				t.Errorf("error = %v, want a 400 %q", err, test.wantErr)
+			}
+		})
+	}
+
+	// Host ports are fine without the preset
+	podSpec := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "web", Ports: []apiv1.ContainerPort{{Name: "http", ContainerPort: 8080, HostPort: 80}}}}}
+	if err := applySecurityContext(&podSpec, &config.SecurityContextStruct{RunAsUser: "1000"}); err != nil {
+		t.Errorf("host port without the restricted preset: %v", err)
+	}
+}
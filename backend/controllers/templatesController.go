@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// A {{variable}} in a template - spaces inside the braces are allowed, e.g. {{ name }}
+var templateVariable = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// List the saved deployment templates
+func ListTemplates(c *fiber.Ctx) error {
+	templates := config.Templates().List()
+	for _, template := range templates {
+		zap.L().Info(" * " + template.Name)
+	}
+
+	return c.JSON(fiber.Map{"templates": templates})
+}
+
+// Get a specific deployment template
+func GetTemplate(c *fiber.Ctx) error {
+	template, err := config.Templates().Get(c.Params("name"))
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"template": template})
+}
+
+// Save a new deployment template
+// The body is a name, an optional description and a partially filled in CreateDeploymentStruct under "deployment", e.g.
+// {"name": "web", "deployment": {"deploymentName": "{{name}}", "containerImageName": "nginx", "containerImageTag": "{{tag}}", "replicaCount": "2"}}
+func CreateTemplate(c *fiber.Ctx) error {
+	var template = config.DeploymentTemplate{}
+	// Parse the request body into the template struct
+	if err := c.BodyParser(&template); err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validateTemplate(&template); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	saved, err := config.Templates().Create(template)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	zap.L().Info("Created template " + saved.Name)
+
+	return c.JSON(fiber.Map{"message": "Created template " + saved.Name, "template": saved})
+}
+
+// Replace an existing deployment template
+// This takes the same body as CreateTemplate - the name can be left out, and can't be changed
+func UpdateTemplate(c *fiber.Ctx) error {
+	var template = config.DeploymentTemplate{}
+	// Parse the request body into the template struct
+	if err := c.BodyParser(&template); err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if template.Name != "" && template.Name != c.Params("name") {
+		return c.Status(400).JSON(fiber.Map{"error": newFieldError("name", "can't be changed").Error()})
+	}
+	template.Name = c.Params("name")
+	if err := validateTemplate(&template); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	saved, err := config.Templates().Update(template)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	zap.L().Info("Updated template " + saved.Name)
+
+	return c.JSON(fiber.Map{"message": "Updated template " + saved.Name, "template": saved})
+}
+
+// Delete a deployment template
+func DeleteTemplate(c *fiber.Ctx) error {
+	if err := config.Templates().Delete(c.Params("name")); err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	zap.L().Info("Deleted template " + c.Params("name"))
+
+	return c.JSON(fiber.Map{"message": "Deleted template " + c.Params("name")})
+}
+
+// Create a deployment from a template
+// The body gives the value of every variable the template uses, e.g. {"variables": {"name": "web-2", "tag": "v2"}}
+// The filled in template is created the same as a POST /deployment/create body, in the request's namespace and context
+func InstantiateTemplate(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	template, err := config.Templates().Get(c.Params("name"))
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	var instantiateTemplateStruct = config.InstantiateTemplateStruct{}
+	// Parse the request body into the instantiateTemplateStruct struct
+	if err := c.BodyParser(&instantiateTemplateStruct); err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	createDeploymentStruct, err := instantiateTemplate(template, instantiateTemplateStruct.Variables)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	zap.L().Info(dryRunMessage(dryRun, "Instantiating template "+template.Name))
+
+	return respondCreateDeployment(c, cluster, namespace, createDeploymentStruct, dryRun)
+}
+
+// Check a template before it's saved, and record the variables it uses
+func validateTemplate(template *config.DeploymentTemplate) error {
+	if template.Name == "" {
+		return newFieldError("name", "is required")
+	}
+	if errs := validation.IsDNS1123Subdomain(template.Name); len(errs) > 0 {
+		return newFieldError("name", "%q is invalid: %s", template.Name, strings.Join(errs, ", "))
+	}
+	if len(template.Deployment) == 0 {
+		return newFieldError("deployment", "is required")
+	}
+	// The deployment has to have the shape of a CreateDeploymentStruct - variables can only be used in strings, so this is checked as is
+	if _, err := decodeTemplateDeployment(template.Deployment); err != nil {
+		return err
+	}
+	if err := validateTemplateCredentials(template.Deployment); err != nil {
+		return err
+	}
+	if err := validateTemplateKeys("deployment", template.Deployment); err != nil {
+		return err
+	}
+
+	variables := map[string]bool{}
+	walkTemplateStrings(template.Deployment, func(value string) string {
+		for _, match := range templateVariable.FindAllStringSubmatch(value, -1) {
+			variables[match[1]] = true
+		}
+		return value
+	})
+	template.Variables = []string{}
+	for variable := range variables {
+		template.Variables = append(template.Variables, variable)
+	}
+	sort.Strings(template.Variables)
+
+	return nil
+}
+
+// Check a template doesn't store registry credentials - templates are saved in plain text and returned to anyone who lists them
+// The credential fields can only be a {{variable}}, so the credentials are provided each time the template is instantiated
+func validateTemplateCredentials(deployment map[string]interface{}) error {
+	fields := []string{"deployment."}
+	containers := []interface{}{deployment}
+	for _, list := range []string{"containers", "initContainers"} {
+		items, _ := deployment[list].([]interface{})
+		for i, item := range items {
+			fields = append(fields, fmt.Sprintf("deployment.%s[%d].", list, i))
+			containers = append(containers, item)
+		}
+	}
+	for i, container := range containers {
+		containerFields, _ := container.(map[string]interface{})
+		for _, credential := range []string{"registryUsername", "registryPassword"} {
+			// Anything left once the variables are removed would be stored as is
+			if value, _ := containerFields[credential].(string); templateVariable.ReplaceAllString(value, "") != "" {
+				return newFieldError(fields[i]+credential, "can't be stored in a template, use a variable such as {{%s}} instead", credential)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Fill in the variables of a template to get the CreateDeploymentStruct it describes
+// Every variable the template uses needs a value, and values for variables it doesn't use are rejected, so typos aren't silently ignored
+func instantiateTemplate(template config.DeploymentTemplate, values map[string]string) (config.CreateDeploymentStruct, error) {
+	used := map[string]bool{}
+	for _, variable := range template.Variables {
+		if _, ok := values[variable]; !ok {
+			return config.CreateDeploymentStruct{}, newFieldError("variables."+variable, "is required")
+		}
+		used[variable] = true
+	}
+	for variable := range values {
+		if !used[variable] {
+			return config.CreateDeploymentStruct{}, newFieldError("variables."+variable, "isn't used by template %s", template.Name)
+		}
+	}
+
+	// The stored template is copied through JSON, so filling it in doesn't change it
+	data, err := json.Marshal(template.Deployment)
+	if err != nil {
+		return config.CreateDeploymentStruct{}, err
+	}
+	var deployment map[string]interface{}
+	if err := json.Unmarshal(data, &deployment); err != nil {
+		return config.CreateDeploymentStruct{}, err
+	}
+	walkTemplateStrings(deployment, func(value string) string {
+		return templateVariable.ReplaceAllStringFunc(value, func(match string) string {
+			return values[templateVariable.FindStringSubmatch(match)[1]]
+		})
+	})
+
+	return decodeTemplateDeployment(deployment)
+}
+
+// Decode the deployment of a template into a CreateDeploymentStruct
+// Unknown fields are rejected, so a misspelt field in a template is caught when it's saved rather than ignored on every instantiation
+func decodeTemplateDeployment(deployment map[string]interface{}) (config.CreateDeploymentStruct, error) {
+	createDeploymentStruct := config.CreateDeploymentStruct{}
+	data, err := json.Marshal(deployment)
+	if err != nil {
+		return createDeploymentStruct, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&createDeploymentStruct); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return createDeploymentStruct, newFieldError("deployment."+typeErr.Field, "must be a %s, got a %s", typeErr.Type.String(), typeErr.Value)
+		}
+		return createDeploymentStruct, newFieldError("deployment", "%s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	return createDeploymentStruct, nil
+}
+
+// Call fn on every string value in a decoded JSON document and replace it with what it returns
+// Map keys are left as is - see validateTemplateKeys
+func walkTemplateStrings(value interface{}, fn func(string) string) interface{} {
+	switch typed := value.(type) {
+	case string:
+		return fn(typed)
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = walkTemplateStrings(item, fn)
+		}
+	case []interface{}:
+		for i := range typed {
+			typed[i] = walkTemplateStrings(typed[i], fn)
+		}
+	}
+
+	return value
+}
+
+// Check no map key in a template holds a variable, e.g. a label name
+// Filling in a key could collide with another key in the same map, and which one is kept would be down to chance
+func validateTemplateKeys(field string, value interface{}) error {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if templateVariable.MatchString(key) {
+				return newFieldError(field+"."+key, "variables can only be used in values, not in keys")
+			}
+			if err := validateTemplateKeys(field+"."+key, item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range typed {
+			if err := validateTemplateKeys(fmt.Sprintf("%s[%d]", field, i), item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+)
+
+// Decode a template deployment from JSON, the same as it's received in a request
+func templateDeployment(t *testing.T, deployment string) map[string]interface{} {
+	t.Helper()
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(deployment), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	return decoded
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name          string
+		deployment    string
+		wantVariables []string
+		wantErr       string
+	}{
+		{
+			name:          "variables are collected once and sorted",
+			deployment:    `{"deploymentName": "{{name}}", "deploymentLabel": "{{ name }}", "containerImageTag": "{{tag}}", "podLabels": {"team": "{{team}}"}}`,
+			wantVariables: []string{"name", "tag", "team"},
+		},
+		{
+			name:          "no variables",
+			deployment:    `{"deploymentName": "web", "containerImageName": "nginx"}`,
+			wantVariables: []string{},
+		},
+		{
+			name:          "credentials as variables",
+			deployment:    `{"registryType": "private", "registryUsername": "{{username}}", "registryPassword": "{{password}}"}`,
+			wantVariables: []string{"password", "username"},
+		},
+		{
+			name:       "unknown field",
+			deployment: `{"deploymentNam": "web"}`,
+			wantErr:    `deployment: unknown field "deploymentNam"`,
+		},
+		{
+			name:       "wrong type",
+			deployment: `{"replicaCount": 3}`,
+			wantErr:    "deployment.replicaCount: must be a string, got a number",
+		},
+		{
+			name:       "stored password",
+			deployment: `{"registryType": "private", "registryUsername": "{{username}}", "registryPassword": "hunter2"}`,
+			wantErr:    "deployment.registryPassword: can't be stored in a template, use a variable such as {{registryPassword}} instead",
+		},
+		{
+			name:       "password partly a variable",
+			deployment: `{"registryPassword": "hunter{{n}}"}`,
+			wantErr:    "deployment.registryPassword: can't be stored in a template, use a variable such as {{registryPassword}} instead",
+		},
+		{
+			name:       "stored username in a sidecar",
+			deployment: `{"containers": [{"containerName": "sidecar", "registryUsername": "admin"}]}`,
+			wantErr:    "deployment.containers[0].registryUsername: can't be stored in a template, use a variable such as {{registryUsername}} instead",
+		},
+		{
+			name:       "variable in a key",
+			deployment: `{"labels": {"{{key}}": "v", "team": "x"}}`,
+			wantErr:    "deployment.labels.{{key}}: variables can only be used in values, not in keys",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template := config.DeploymentTemplate{Name: "web", Deployment: templateDeployment(t, test.deployment)}
+			err := validateTemplate(&template)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(template.Variables, test.wantVariables) {
+				t.Errorf("variables = %v, want %v", template.Variables, test.wantVariables)
+			}
+		})
+	}
+}
+
+func TestInstantiateTemplate(t *testing.T) {
+	template := config.DeploymentTemplate{
+		Name:       "web",
+		Deployment: templateDeployment(t, `{"deploymentName": "{{name}}", "containerImageName": "nginx", "containerImageTag": "{{ tag }}", "podLabels": {"team": "{{team}}", "tier": "{{name}}-{{tag}}"}, "containers": [{"containerName": "{{name}}-sidecar"}]}`),
+	}
+	if err := validateTemplate(&template); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		variables map[string]string
+		wantErr   string
+	}{
+		{name: "missing variable", variables: map[string]string{"name": "api", "tag": "v2"}, wantErr: "variables.team: is required"},
+		{name: "unused variable", variables: map[string]string{"name": "api", "tag": "v2", "team": "a", "tga": "v3"}, wantErr: "variables.tga: isn't used by template web"},
+		{name: "values are used as is", variables: map[string]string{"name": "api", "tag": "v2", "team": `"quoted" {{name}}`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			createDeploymentStruct, err := instantiateTemplate(template, test.variables)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if createDeploymentStruct.DeploymentName != "api" || createDeploymentStruct.ContainerImageTag != "v2" || createDeploymentStruct.ContainerImageName != "nginx" {
+				t.Errorf("deployment = %+v", createDeploymentStruct)
+			}
+			// A value holding what looks like a variable isn't filled in again
+			wantLabels := map[string]string{"team": `"quoted" {{name}}`, "tier": "api-v2"}
+			if !reflect.DeepEqual(createDeploymentStruct.PodLabels, wantLabels) {
+				t.Errorf("podLabels = %v, want %v", createDeploymentStruct.PodLabels, wantLabels)
+			}
+			if len(createDeploymentStruct.Containers) != 1 || createDeploymentStruct.Containers[0].ContainerName != "api-sidecar" {
+				t.Errorf("containers = %+v", createDeploymentStruct.Containers)
+			}
+		})
+	}
+
+	// Instantiating doesn't change the stored template
+	if got := template.Deployment["deploymentName"]; got != "{{name}}" {
+		t.Errorf("template deploymentName = %v, want {{name}}", got)
+	}
+}
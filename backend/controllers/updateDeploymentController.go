@@ -0,0 +1,590 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Update an existing deployment
+// This accepts the same fields as CreateDeployment - only the fields provided are applied, everything else is left as is
+func UpdateDeployment(c *fiber.Ctx) error {
+	cluster, err := getCluster(c)
+	if err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := getNamespace(c)
+	zap.L().Info("Using namespace: " + namespace)
+
+	// Check if the parameter is empty - if so, return a 400 for bad request
+	if c.Params("deployment") == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Deployment name is required"})
+	}
+
+	deploymentName := c.Params("deployment")
+	zap.L().Info("User provided deployment name: " + deploymentName)
+	dryRun, err := getDryRun(c)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var updateDeploymentStruct = config.CreateDeploymentStruct{}
+	// Parse the request body into the updateDeploymentStruct struct
+	if err := c.BodyParser(&updateDeploymentStruct); err != nil {
+		zap.L().Error(err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	// Validate the provided fields before making any changes
+	if err := validateDeploymentUpdate(deploymentName, updateDeploymentStruct); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	deploymentsClient := cluster.Clientset.AppsV1().Deployments(namespace)
+	secretClient := cluster.Clientset.CoreV1().Secrets(namespace)
+	var result *appsv1.Deployment
+	// The image pull secret as it was before a switch to a private registry changed it, so it can be restored if the update fails
+	var previousSecret *apiv1.Secret
+	var secretChanged bool
+	// The registry whose credentials are removed from the image pull secret once the update has been made
+	var removeRegistry bool
+	var removedRegistryServer string
+	// Get the latest version of the deployment and apply the changes to it
+	// If someone else updates the deployment in between, the update fails with a conflict and is retried against the newer version
+	updateErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// The deployment is read from the API server rather than the cache, so the update is made against the latest resourceVersion
+		deployment, err := deploymentsClient.Get(context.TODO(), deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		previousRegistryServer, registryServer, err := applyDeploymentUpdate(deployment, updateDeploymentStruct)
+		if err != nil {
+			return err
+		}
+		// Switching the main container to a public registry removes its credentials from the image pull secret after the update, since the
+		// current pods may still need them - see releaseRegistry
+		// Switching to, or updating the credentials of, a private registry creates or updates the secret before the update so the new pods can pull their image
+		switch updateDeploymentStruct.RegistryType {
+		case "public":
+			if removeRegistry, err = releaseRegistry(secretClient, deploymentName, &deployment.Spec.Template.Spec, previousRegistryServer); err != nil {
+				return err
+			}
+			removedRegistryServer = previousRegistryServer
+		case "private":
+			if !secretChanged {
+				previousSecret, err = secretClient.Get(context.TODO(), config.ImagePullSecretName(deploymentName), metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					previousSecret = nil
+				} else if err != nil {
+					return err
+				}
+			}
+			if err := applyImagePullSecret(secretClient, dryRun, deploymentName, registryServer, updateDeploymentStruct.RegistryUsername, updateDeploymentStruct.RegistryPassword); err != nil {
+				return err
+			}
+			secretChanged = true
+			deployment.Spec.Template.Spec.ImagePullSecrets = append(removeImagePullSecret(deployment.Spec.Template.Spec.ImagePullSecrets, config.ImagePullSecretName(deploymentName)), apiv1.LocalObjectReference{Name: config.ImagePullSecretName(deploymentName)})
+		}
+
+		zap.L().Info(dryRunMessage(dryRun, "Updating deployment "+deploymentName))
+		result, err = deploymentsClient.Update(context.TODO(), deployment, metav1.UpdateOptions{DryRun: dryRun})
+		return err
+	})
+	if updateErr != nil {
+		zap.L().Error(updateErr.Error())
+		// Don't leave the image pull secret changed for an update that wasn't made
+		if secretChanged && len(dryRun) == 0 {
+			if err := restoreImagePullSecret(secretClient, deploymentName, previousSecret); err != nil {
+				zap.L().Error("Failed to restore the image pull secret for deployment " + deploymentName + ": " + err.Error())
+			}
+		}
+		return c.Status(errorStatus(updateErr)).JSON(fiber.Map{"error": updateErr.Error()})
+	}
+
+	if removeRegistry {
+		if err := removeRegistryAuth(secretClient, dryRun, deploymentName, removedRegistryServer); err != nil {
+			zap.L().Error(err.Error())
+			return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	// A dry run returns the deployment as the API server would have stored it
+	if len(dryRun) > 0 {
+		return c.JSON(fiber.Map{"message": dryRunMessage(dryRun, "Updated deployment "+result.GetName()), "deployment": result})
+	}
+
+	zap.L().Info("Updated deployment " + result.GetName())
+	waitForCache(func() bool {
+		deployment, err := cluster.DeploymentLister.Deployments(namespace).Get(result.GetName())
+		return err == nil && deployment.GetResourceVersion() == result.GetResourceVersion()
+	})
+
+	return c.JSON(fiber.Map{"message": "Updated deployment " + result.GetName(), "deployment": result})
+}
+
+// Validate the fields of an update that can be checked without the deployment - the deployment name and label can't be changed,
+// the name is immutable and the label is part of the immutable selector
+func validateDeploymentUpdate(deploymentName string, updateDeploymentStruct config.CreateDeploymentStruct) error {
+	if updateDeploymentStruct.DeploymentName != "" && updateDeploymentStruct.DeploymentName != deploymentName {
+		return newFieldError("deploymentName", "can't be changed")
+	}
+	if updateDeploymentStruct.ReplicaCount != "" {
+		if _, err := parseInt32Field("replicaCount", updateDeploymentStruct.ReplicaCount, 0); err != nil {
+			return err
+		}
+	}
+	if updateDeploymentStruct.RegistryType != "" && updateDeploymentStruct.RegistryType != "private" && updateDeploymentStruct.RegistryType != "public" {
+		return newFieldError("registryType", "must be either private or public")
+	}
+	if updateDeploymentStruct.RegistryType == "private" && (updateDeploymentStruct.RegistryUsername == "" || updateDeploymentStruct.RegistryPassword == "") {
+		return newFieldError("registryUsername", "is required, along with registryPassword, for a private registry")
+	}
+	// Registry credentials are only updated through the top level fields, which manage the deployment's image pull secret
+	for i, containerStruct := range updateDeploymentStruct.Containers {
+		if containerStruct.RegistryType != "" {
+			return newFieldError(fmt.Sprintf("containers[%d].registryType", i), "can't be changed on an update, use the top level registry fields")
+		}
+	}
+	for i, containerStruct := range updateDeploymentStruct.InitContainers {
+		if containerStruct.RegistryType != "" {
+			return newFieldError(fmt.Sprintf("initContainers[%d].registryType", i), "can't be changed on an update, use the top level registry fields")
+		}
+		if err := validateInitContainerProbes(fmt.Sprintf("initContainers[%d].", i), containerStruct); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply the fields provided in an update to a deployment - the image pull secret is left to the caller
+// This returns the registry server of the main container's image before and after the update
+func applyDeploymentUpdate(deployment *appsv1.Deployment, updateDeploymentStruct config.CreateDeploymentStruct) (string, string, error) {
+	if updateDeploymentStruct.DeploymentLabel != "" && updateDeploymentStruct.DeploymentLabel != deployment.Spec.Selector.MatchLabels["app"] {
+		return "", "", apierrors.NewBadRequest("deploymentLabel can't be changed since it's part of the deployment selector")
+	}
+	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return "", "", apierrors.NewBadRequest("Deployment " + deployment.GetName() + " has no containers to update")
+	}
+
+	if updateDeploymentStruct.ReplicaCount != "" {
+		replicaCount, err := parseInt32Field("replicaCount", updateDeploymentStruct.ReplicaCount, 0)
+		if err != nil {
+			return "", "", err
+		}
+		deployment.Spec.Replicas = config.Int32Ptr(replicaCount)
+	}
+	if err := applyMetadata(deployment, updateDeploymentStruct); err != nil {
+		return "", "", err
+	}
+	// Volumes are replaced as a whole when provided, before the containers so their volume mounts are checked against the new volumes
+	podSpec := &deployment.Spec.Template.Spec
+	if updateDeploymentStruct.Volumes != nil {
+		if err := validateVolumes(updateDeploymentStruct.Volumes); err != nil {
+			return "", "", err
+		}
+		podSpec.Volumes = updateDeploymentStruct.Volumes
+	}
+	// The top level container fields are applied to the main container, which is the first container
+	previousRegistryServer, _, _, _ := splitImage(podSpec.Containers[0].Image)
+	registryServer, err := updateContainer("", &podSpec.Containers[0], updateDeploymentStruct.ContainerStruct, podSpec.Volumes)
+	if err != nil {
+		return "", "", err
+	}
+	// Renaming the main container can clash with a sidecar or init container, which the API server would reject with a less useful error
+	// This is checked before the named containers are updated, since they're matched by name
+	if updateDeploymentStruct.ContainerName != "" {
+		names := map[string]bool{}
+		for _, container := range append(append([]apiv1.Container{}, podSpec.Containers[1:]...), podSpec.InitContainers...) {
+			names[container.Name] = true
+		}
+		if err := claimContainerName("containerName", podSpec.Containers[0].Name, names); err != nil {
+			return "", "", err
+		}
+	}
+	// The credentials are stored under the registry server, which comes from containerRegistryServer or the current image
+	if updateDeploymentStruct.RegistryType == "private" && registryServer == "" {
+		return "", "", newFieldError("containerRegistryServer", "is required for a private registry - the image %s doesn't name one", podSpec.Containers[0].Image)
+	}
+	// Entries in containers and initContainers are matched to the existing containers by name
+	for i, containerStruct := range updateDeploymentStruct.Containers {
+		if err := updateNamedContainer(fmt.Sprintf("containers[%d].", i), podSpec.Containers, containerStruct, podSpec.Volumes); err != nil {
+			return "", "", err
+		}
+	}
+	for i, containerStruct := range updateDeploymentStruct.InitContainers {
+		if err := updateNamedContainer(fmt.Sprintf("initContainers[%d].", i), podSpec.InitContainers, containerStruct, podSpec.Volumes); err != nil {
+			return "", "", err
+		}
+	}
+	// Removing a volume that a container still mounts would be rejected by the API server
+	if err := validatePodVolumeMounts(podSpec); err != nil {
+		return "", "", err
+	}
+	if err := applyScheduling(deployment, updateDeploymentStruct); err != nil {
+		return "", "", err
+	}
+	if err := applySecurityContext(podSpec, updateDeploymentStruct.SecurityContext); err != nil {
+		return "", "", err
+	}
+	if err := updateRolloutStrategy(deployment, updateDeploymentStruct); err != nil {
+		return "", "", err
+	}
+
+	return previousRegistryServer, registryServer, nil
+}
+
+// Apply the rollout strategy fields provided in an update on top of the deployment's current settings
+// The current settings are converted back to request fields so the same validation as CreateDeployment is used
+func updateRolloutStrategy(deployment *appsv1.Deployment, updateDeploymentStruct config.CreateDeploymentStruct) error {
+	if updateDeploymentStruct.Strategy == "" && updateDeploymentStruct.MaxSurge == "" && updateDeploymentStruct.MaxUnavailable == "" &&
+		updateDeploymentStruct.MinReadySeconds == "" && updateDeploymentStruct.RevisionHistoryLimit == "" && updateDeploymentStruct.ProgressDeadlineSeconds == "" {
+		return nil
+	}
+
+	current := config.CreateDeploymentStruct{
+		Strategy:        string(deployment.Spec.Strategy.Type),
+		MinReadySeconds: strconv.Itoa(int(deployment.Spec.MinReadySeconds)),
+	}
+	if deployment.Spec.Strategy.RollingUpdate != nil {
+		if deployment.Spec.Strategy.RollingUpdate.MaxSurge != nil {
+			current.MaxSurge = deployment.Spec.Strategy.RollingUpdate.MaxSurge.String()
+		}
+		if deployment.Spec.Strategy.RollingUpdate.MaxUnavailable != nil {
+			current.MaxUnavailable = deployment.Spec.Strategy.RollingUpdate.MaxUnavailable.String()
+		}
+	}
+	if deployment.Spec.RevisionHistoryLimit != nil {
+		current.RevisionHistoryLimit = strconv.Itoa(int(*deployment.Spec.RevisionHistoryLimit))
+	}
+	if deployment.Spec.ProgressDeadlineSeconds != nil {
+		current.ProgressDeadlineSeconds = strconv.Itoa(int(*deployment.Spec.ProgressDeadlineSeconds))
+	}
+
+	// Switching strategy drops the current maxSurge and maxUnavailable, unless they're provided again
+	if updateDeploymentStruct.Strategy != "" && updateDeploymentStruct.Strategy != current.Strategy {
+		current.Strategy = updateDeploymentStruct.Strategy
+		current.MaxSurge, current.MaxUnavailable = "", ""
+	}
+	if updateDeploymentStruct.MaxSurge != "" {
+		current.MaxSurge = updateDeploymentStruct.MaxSurge
+	}
+	if updateDeploymentStruct.MaxUnavailable != "" {
+		current.MaxUnavailable = updateDeploymentStruct.MaxUnavailable
+	}
+	if updateDeploymentStruct.MinReadySeconds != "" {
+		current.MinReadySeconds = updateDeploymentStruct.MinReadySeconds
+	}
+	if updateDeploymentStruct.RevisionHistoryLimit != "" {
+		current.RevisionHistoryLimit = updateDeploymentStruct.RevisionHistoryLimit
+	}
+	if updateDeploymentStruct.ProgressDeadlineSeconds != "" {
+		current.ProgressDeadlineSeconds = updateDeploymentStruct.ProgressDeadlineSeconds
+	}
+
+	deployment.Spec.Strategy = appsv1.DeploymentStrategy{}
+	return applyRolloutStrategy(deployment, current)
+}
+
+// Split an image into the registry server, image name, tag and digest - e.g. "docker.io/library/nginx:1.27" returns "docker.io", "library/nginx", "1.27", ""
+// and "nginx@sha256:abcd" returns "", "nginx", "", "sha256:abcd"
+// The first path segment is only treated as the registry server if it looks like a host, which is the same rule Docker uses
+func splitImage(image string) (string, string, string, string) {
+	var registryServer, imageTag, digest string
+	imageName := image
+	// The digest is split off first, since it contains a ":" of its own
+	if i := strings.Index(imageName, "@"); i != -1 {
+		imageName, digest = imageName[:i], imageName[i+1:]
+	}
+	if i := strings.LastIndex(imageName, ":"); i > strings.LastIndex(imageName, "/") {
+		imageName, imageTag = imageName[:i], imageName[i+1:]
+	}
+	if i := strings.Index(imageName, "/"); i != -1 {
+		if host := imageName[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			registryServer, imageName = host, imageName[i+1:]
+		}
+	}
+
+	return registryServer, imageName, imageTag, digest
+}
+
+// Join the parts of an image back together, the inverse of splitImage
+func joinImage(registryServer string, imageName string, imageTag string, digest string) string {
+	image := imageName
+	if registryServer != "" {
+		image = registryServer + "/" + image
+	}
+	if imageTag != "" {
+		image = image + ":" + imageTag
+	}
+	if digest != "" {
+		image = image + "@" + digest
+	}
+
+	return image
+}
+
+// Apply the provided fields of a container in an update - field is the path of the container in the request, used to name invalid fields
+// This returns the registry server of the container's image after the update
+func updateContainer(field string, container *apiv1.Container, containerStruct config.ContainerStruct, volumes []apiv1.Volume) (string, error) {
+	if containerStruct.ContainerName != "" {
+		container.Name = containerStruct.ContainerName
+	}
+	// Each part of the image can be changed on its own - e.g. only the tag
+	// A digest pins the exact image, so it's dropped when the image name or tag changes - a digest is kept when only the registry changes, e.g. to a mirror
+	registryServer, imageName, imageTag, digest := splitImage(container.Image)
+	if containerStruct.ContainerRegistryServer != "" {
+		registryServer = containerStruct.ContainerRegistryServer
+	}
+	if containerStruct.ContainerImageName != "" {
+		imageName, digest = containerStruct.ContainerImageName, ""
+	}
+	if containerStruct.ContainerImageTag != "" {
+		imageTag, digest = containerStruct.ContainerImageTag, ""
+	}
+	container.Image = joinImage(registryServer, imageName, imageTag, digest)
+	// Ports are replaced as a whole when provided, while containerPort only changes the port named "http"
+	if containerStruct.Ports != nil {
+		if containerStruct.ContainerPort != "" {
+			return "", newFieldError(field+"containerPort", "can't be set together with ports")
+		}
+		ports, err := buildPorts(field, containerStruct.Ports)
+		if err != nil {
+			return "", err
+		}
+		container.Ports = ports
+	}
+	if containerStruct.ContainerPort != "" {
+		containerPort, err := parsePortField(field+"containerPort", containerStruct.ContainerPort)
+		if err != nil {
+			return "", err
+		}
+		setContainerPort(container, containerPort)
+	}
+	// Environment variables are replaced as a whole when provided - an empty list removes them
+	if containerStruct.Env != nil || containerStruct.EnvFrom != nil {
+		env, envFrom := container.Env, container.EnvFrom
+		if containerStruct.Env != nil {
+			env = containerStruct.Env
+		}
+		if containerStruct.EnvFrom != nil {
+			envFrom = containerStruct.EnvFrom
+		}
+		if err := validateEnv(field, env, envFrom); err != nil {
+			return "", err
+		}
+		container.Env, container.EnvFrom = env, envFrom
+	}
+	// Volume mounts are also replaced as a whole when provided
+	if containerStruct.VolumeMounts != nil {
+		if err := validateVolumeMounts(field, containerStruct.VolumeMounts, volumes); err != nil {
+			return "", err
+		}
+		container.VolumeMounts = containerStruct.VolumeMounts
+	}
+	if err := applyResources(field, container, containerStruct); err != nil {
+		return "", err
+	}
+	// Probes are applied after the port so a probe without a port targets the updated one
+	if err := applyProbes(field, container, containerStruct); err != nil {
+		return "", err
+	}
+
+	return registryServer, nil
+}
+
+// Apply an update to the container with the same name - containers can't be renamed this way as the name is what identifies them
+func updateNamedContainer(field string, containers []apiv1.Container, containerStruct config.ContainerStruct, volumes []apiv1.Volume) error {
+	if containerStruct.ContainerName == "" {
+		return newFieldError(field+"containerName", "is required to identify the container to update")
+	}
+	for i := range containers {
+		if containers[i].Name == containerStruct.ContainerName {
+			_, err := updateContainer(field, &containers[i], containerStruct, volumes)
+			return err
+		}
+	}
+
+	return newFieldError(field+"containerName", "no container named %q in the deployment", containerStruct.ContainerName)
+}
+
+// Set the port number of a container - the port named "http" created by CreateDeployment is updated, or added if it doesn't exist
+func setContainerPort(container *apiv1.Container, containerPort int32) {
+	for i := range container.Ports {
+		if container.Ports[i].Name == "http" {
+			container.Ports[i].ContainerPort = containerPort
+			return
+		}
+	}
+
+	container.Ports = append(container.Ports, apiv1.ContainerPort{Name: "http", Protocol: apiv1.ProtocolTCP, ContainerPort: containerPort})
+}
+
+// Create the image pull secret for a deployment, or add the registry credentials to it if it already exists
+func applyImagePullSecret(secretClient corev1client.SecretInterface, dryRun []string, deploymentName string, registryServer string, registryUsername string, registryPassword string) error {
+	auth := config.RegistryAuth{Username: registryUsername, Password: registryPassword}
+	existing, err := secretClient.Get(context.TODO(), config.ImagePullSecretName(deploymentName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret, err := config.NewImagePullSecret(deploymentName, map[string]config.RegistryAuth{registryServer: auth})
+		if err != nil {
+			return err
+		}
+		if _, err := secretClient.Create(context.TODO(), secret, metav1.CreateOptions{DryRun: dryRun}); err != nil {
+			return err
+		}
+		zap.L().Info(dryRunMessage(dryRun, "Created secret "+secret.GetName()))
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Credentials for the other registries the deployment's containers use are kept
+	auths, err := config.ImagePullSecretAuths(existing)
+	if err != nil {
+		return err
+	}
+	auths[registryServer] = auth
+	secret, err := config.NewImagePullSecret(deploymentName, auths)
+	if err != nil {
+		return err
+	}
+	existing.Data = secret.Data
+	if _, err := secretClient.Update(context.TODO(), existing, metav1.UpdateOptions{DryRun: dryRun}); err != nil {
+		return err
+	}
+	zap.L().Info(dryRunMessage(dryRun, "Updated secret "+existing.GetName()))
+
+	return nil
+}
+
+// Work out what switching the main container away from a registry frees up, once the update has been applied to the pod spec
+// The registry's credentials are only removed if no container or init container still pulls from it, and the reference to the image pull secret
+// is removed as well when no other credentials are left. This returns whether to remove the registry's credentials once the update is made
+func releaseRegistry(secretClient corev1client.SecretInterface, deploymentName string, podSpec *apiv1.PodSpec, registryServer string) (bool, error) {
+	if registryInUse(podSpec, registryServer) {
+		return false, nil
+	}
+	remaining, err := remainingRegistryAuths(secretClient, deploymentName, registryServer)
+	if err != nil {
+		return false, err
+	}
+	if remaining == 0 {
+		podSpec.ImagePullSecrets = removeImagePullSecret(podSpec.ImagePullSecrets, config.ImagePullSecretName(deploymentName))
+	}
+
+	return true, nil
+}
+
+// Check whether any container or init container of a pod pulls its image from a registry
+func registryInUse(podSpec *apiv1.PodSpec, registryServer string) bool {
+	for _, container := range append(append([]apiv1.Container{}, podSpec.InitContainers...), podSpec.Containers...) {
+		if server, _, _, _ := splitImage(container.Image); server == registryServer {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Count the registries left in the image pull secret of a deployment once the credentials for a registry are removed
+func remainingRegistryAuths(secretClient corev1client.SecretInterface, deploymentName string, registryServer string) (int, error) {
+	existing, err := secretClient.Get(context.TODO(), config.ImagePullSecretName(deploymentName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	auths, err := config.ImagePullSecretAuths(existing)
+	if err != nil {
+		return 0, err
+	}
+	delete(auths, registryServer)
+
+	return len(auths), nil
+}
+
+// Remove the credentials for a registry from the image pull secret of a deployment
+// When no credentials are left, the secret is deleted
+func removeRegistryAuth(secretClient corev1client.SecretInterface, dryRun []string, deploymentName string, registryServer string) error {
+	existing, err := secretClient.Get(context.TODO(), config.ImagePullSecretName(deploymentName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	auths, err := config.ImagePullSecretAuths(existing)
+	if err != nil {
+		return err
+	}
+	if _, ok := auths[registryServer]; !ok && len(auths) > 0 {
+		return nil
+	}
+	delete(auths, registryServer)
+	if len(auths) == 0 {
+		if err := secretClient.Delete(context.TODO(), existing.GetName(), metav1.DeleteOptions{DryRun: dryRun}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		zap.L().Info(dryRunMessage(dryRun, "Removed ImagePullSecrets for deployment: "+deploymentName))
+		return nil
+	}
+
+	secret, err := config.NewImagePullSecret(deploymentName, auths)
+	if err != nil {
+		return err
+	}
+	existing.Data = secret.Data
+	if _, err := secretClient.Update(context.TODO(), existing, metav1.UpdateOptions{DryRun: dryRun}); err != nil {
+		return err
+	}
+	zap.L().Info(dryRunMessage(dryRun, "Removed credentials for registry "+registryServer+" from secret "+existing.GetName()))
+
+	return nil
+}
+
+// Put the image pull secret of a deployment back the way it was - a nil secret means it didn't exist, so it's deleted
+func restoreImagePullSecret(secretClient corev1client.SecretInterface, deploymentName string, previous *apiv1.Secret) error {
+	if previous == nil {
+		if err := secretClient.Delete(context.TODO(), config.ImagePullSecretName(deploymentName), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		zap.L().Info("Deleted secret " + config.ImagePullSecretName(deploymentName) + " created for a failed update")
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := secretClient.Get(context.TODO(), previous.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		existing.Data = previous.Data
+		if _, err := secretClient.Update(context.TODO(), existing, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		zap.L().Info("Restored secret " + previous.GetName() + " after a failed update")
+		return nil
+	})
+}
+
+// Remove a secret from a list of image pull secrets
+func removeImagePullSecret(imagePullSecrets []apiv1.LocalObjectReference, secretName string) []apiv1.LocalObjectReference {
+	remaining := []apiv1.LocalObjectReference{}
+	for _, s := range imagePullSecrets {
+		if s.Name != secretName {
+			remaining = append(remaining, s)
+		}
+	}
+
+	return remaining
+}
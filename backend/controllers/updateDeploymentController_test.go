@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	config "github.com/Ajsalemo/kubernetes-client-application/config"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image                                       string
+		registryServer, imageName, imageTag, digest string
+	}{
+		{"nginx", "", "nginx", "", ""},
+		{"nginx:1.27", "", "nginx", "1.27", ""},
+		{"library/nginx:1.27", "", "library/nginx", "1.27", ""},
+		{"docker.io/library/nginx:1.27", "docker.io", "library/nginx", "1.27", ""},
+		{"localhost/app", "localhost", "app", "", ""},
+		{"localhost:5000/app:v1", "localhost:5000", "app", "v1", ""},
+		{"myregistry.azurecr.io/team/app", "myregistry.azurecr.io", "team/app", "", ""},
+		{"nginx@sha256:abcd", "", "nginx", "", "sha256:abcd"},
+		{"nginx:1.27@sha256:abcd", "", "nginx", "1.27", "sha256:abcd"},
+		{"localhost:5000/app@sha256:abcd", "localhost:5000", "app", "", "sha256:abcd"},
+	}
+	for _, test := range tests {
+		registryServer, imageName, imageTag, digest := splitImage(test.image)
+		if registryServer != test.registryServer || imageName != test.imageName || imageTag != test.imageTag || digest != test.digest {
+			t.Errorf("splitImage(%q) = %q, %q, %q, %q, want %q, %q, %q, %q", test.image, registryServer, imageName, imageTag, digest, test.registryServer, test.imageName, test.imageTag, test.digest)
+		}
+		// Joining the parts gives back the same image
+		if image := joinImage(registryServer, imageName, imageTag, digest); image != test.image {
+			t.Errorf("joinImage(splitImage(%q)) = %q", test.image, image)
+		}
+	}
+}
+
+func TestUpdateContainerImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		image     string
+		update    config.ContainerStruct
+		wantImage string
+	}{
+		{"tag replaces a digest", "nginx@sha256:abcd", config.ContainerStruct{ContainerImageTag: "v2"}, "nginx:v2"},
+		{"tag replaces a tag and digest", "nginx:1.27@sha256:abcd", config.ContainerStruct{ContainerImageTag: "v2"}, "nginx:v2"},
+		{"image name drops the digest", "nginx@sha256:abcd", config.ContainerStruct{ContainerImageName: "httpd"}, "httpd"},
+		{"registry keeps the digest", "nginx@sha256:abcd", config.ContainerStruct{ContainerRegistryServer: "mirror.example.com"}, "mirror.example.com/nginx@sha256:abcd"},
+		{"tag only", "docker.io/library/nginx:1.27", config.ContainerStruct{ContainerImageTag: "1.28"}, "docker.io/library/nginx:1.28"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := &apiv1.Container{Name: "app", Image: test.image}
+			if _, err := updateContainer("", container, test.update, nil); err != nil {
+				t.Fatal(err)
+			}
+			if container.Image != test.wantImage {
+				t.Errorf("image = %q, want %q", container.Image, test.wantImage)
+			}
+		})
+	}
+}
+
+func TestApplyDeploymentUpdatePrivateRegistry(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{Containers: []apiv1.Container{{Name: "web", Image: "nginx:1.27"}}}},
+		},
+	}
+	update := config.CreateDeploymentStruct{ContainerStruct: config.ContainerStruct{RegistryType: "private", RegistryUsername: "user", RegistryPassword: "password"}}
+	if _, _, err := applyDeploymentUpdate(deployment.DeepCopy(), update); err == nil || errorStatus(err) != 400 {
+		t.Errorf("private registry without a registry server: got %v, want a 400", err)
+	}
+
+	update.ContainerRegistryServer = "myregistry.azurecr.io"
+	_, registryServer, err := applyDeploymentUpdate(deployment.DeepCopy(), update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registryServer != "myregistry.azurecr.io" {
+		t.Errorf("registry server = %q, want myregistry.azurecr.io", registryServer)
+	}
+}
+
+func TestReleaseRegistry(t *testing.T) {
+	const private = "myregistry.azurecr.io"
+	tests := []struct {
+		name string
+		// The images of the pod once the main container has been switched to a public registry
+		containers     []string
+		initContainers []string
+		// The registries the image pull secret holds credentials for
+		auths             []string
+		wantRemove        bool
+		wantPullSecretRef bool
+	}{
+		{
+			name:              "sidecar still uses the registry",
+			containers:        []string{"nginx:1.27", private + "/sidecar:v1"},
+			auths:             []string{private},
+			wantPullSecretRef: true,
+		},
+		{
+			name:              "init container still uses the registry",
+			containers:        []string{"nginx:1.27"},
+			initContainers:    []string{private + "/migrate:v1"},
+			auths:             []string{private},
+			wantPullSecretRef: true,
+		},
+		{
+			name:              "other credentials are left",
+			containers:        []string{"nginx:1.27", "other.example.com/sidecar:v1"},
+			auths:             []string{private, "other.example.com"},
+			wantRemove:        true,
+			wantPullSecretRef: true,
+		},
+		{
+			name:       "no credentials are left",
+			containers: []string{"nginx:1.27", "busybox:1.36"},
+			auths:      []string{private},
+			wantRemove: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auths := map[string]config.RegistryAuth{}
+			for _, registryServer := range test.auths {
+				auths[registryServer] = config.RegistryAuth{Username: "user", Password: "password"}
+			}
+			secret, err := config.NewImagePullSecret("web", auths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret.Namespace = "default"
+			secretClient := fake.NewSimpleClientset(secret).CoreV1().Secrets("default")
+
+			podSpec := &apiv1.PodSpec{ImagePullSecrets: []apiv1.LocalObjectReference{{Name: config.ImagePullSecretName("web")}}}
+			for i, image := range test.containers {
+				podSpec.Containers = append(podSpec.Containers, apiv1.Container{Name: fmt.Sprintf("container-%d", i), Image: image})
+			}
+			for i, image := range test.initContainers {
+				podSpec.InitContainers = append(podSpec.InitContainers, apiv1.Container{Name: fmt.Sprintf("init-%d", i), Image: image})
+			}
+
+			remove, err := releaseRegistry(secretClient, "web", podSpec, private)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if remove != test.wantRemove {
+				t.Errorf("remove = %v, want %v", remove, test.wantRemove)
+			}
+			if hasRef := len(podSpec.ImagePullSecrets) > 0; hasRef != test.wantPullSecretRef {
+				t.Errorf("image pull secret reference kept = %v, want %v", hasRef, test.wantPullSecretRef)
+			}
+			if !remove {
+				return
+			}
+
+			// Once the update is made, the registry's credentials are removed and the secret is deleted if nothing is left
+			if err := removeRegistryAuth(secretClient, nil, "web", private); err != nil {
+				t.Fatal(err)
+			}
+			secret, err = secretClient.Get(context.TODO(), config.ImagePullSecretName("web"), metav1.GetOptions{})
+			if !test.wantPullSecretRef {
+				if err == nil {
+					t.Errorf("secret %s wasn't deleted", secret.GetName())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			remaining, err := config.ImagePullSecretAuths(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := remaining[private]; ok || len(remaining) != len(test.auths)-1 {
+				t.Errorf("credentials left = %v, want all but %s", remaining, private)
+			}
+		})
+	}
+}
+
+func TestApplyDeploymentUpdateContainerName(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+				InitContainers: []apiv1.Container{{Name: "migrate", Image: "busybox:1.36"}},
+				Containers:     []apiv1.Container{{Name: "web", Image: "nginx:1.27"}, {Name: "sidecar", Image: "envoyproxy/envoy:v1.31"}},
+			}},
+		},
+	}
+	tests := []struct {
+		name          string
+		containerName string
+		wantErr       string
+	}{
+		{name: "unchanged", containerName: "web"},
+		{name: "renamed", containerName: "frontend"},
+		{name: "clashes with a sidecar", containerName: "sidecar", wantErr: `containerName: "sidecar" is used by more than one container`},
+		{name: "clashes with an init container", containerName: "migrate", wantErr: `containerName: "migrate" is used by more than one container`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			update := config.CreateDeploymentStruct{ContainerStruct: config.ContainerStruct{ContainerName: test.containerName}}
+			updated := deployment.DeepCopy()
+			_, _, err := applyDeploymentUpdate(updated, update)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if name := updated.Spec.Template.Spec.Containers[0].Name; name != test.containerName {
+					t.Errorf("main container name = %q, want %q", name, test.containerName)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.wantErr || errorStatus(err) != 400 {
+				t.Errorf("error = %v, want a 400 %q", err, test.wantErr)
+			}
+		})
+	}
+}
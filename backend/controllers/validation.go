@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// A request field that failed validation - returned to the client as a 400 naming the field
+type fieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *fieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func newFieldError(field string, format string, args ...interface{}) error {
+	return &fieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// Parse a required int32 field, which must be at least min
+func parseInt32Field(field string, value string, min int32) (int32, error) {
+	if value == "" {
+		return 0, newFieldError(field, "is required")
+	}
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, newFieldError(field, "must be a number, got %q", value)
+	}
+	if int32(parsed) < min {
+		return 0, newFieldError(field, "must be at least %d, got %d", min, parsed)
+	}
+
+	return int32(parsed), nil
+}
+
+// Parse an optional int32 field, which must be at least min - an empty value returns nil so the Kubernetes default is used
+func parseOptionalInt32Field(field string, value string, min int32) (*int32, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := parseInt32Field(field, value, min)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Validate the volumes of a pod - only emptyDir, configMap, secret, persistentVolumeClaim and projected volumes are supported
+func validateVolumes(volumes []apiv1.Volume) error {
+	names := map[string]bool{}
+	for i, volume := range volumes {
+		field := fmt.Sprintf("volumes[%d]", i)
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) > 0 {
+			return newFieldError(field+".name", "%q is invalid: %s", volume.Name, strings.Join(errs, ", "))
+		}
+		if names[volume.Name] {
+			return newFieldError(field+".name", "%q is used by more than one volume", volume.Name)
+		}
+		names[volume.Name] = true
+
+		// Every field of a VolumeSource is a pointer, so clearing the supported sources leaves it empty unless an unsupported one is set
+		unsupported := volume.VolumeSource
+		unsupported.EmptyDir, unsupported.ConfigMap, unsupported.Secret, unsupported.PersistentVolumeClaim, unsupported.Projected = nil, nil, nil, nil, nil
+		if unsupported != (apiv1.VolumeSource{}) {
+			return newFieldError(field, "only emptyDir, configMap, secret, persistentVolumeClaim and projected volumes are supported")
+		}
+		sources := 0
+		if source := volume.EmptyDir; source != nil {
+			sources++
+			if source.Medium != apiv1.StorageMediumDefault && source.Medium != apiv1.StorageMediumMemory {
+				return newFieldError(field+".emptyDir.medium", "must be either empty or Memory, got %q", source.Medium)
+			}
+		}
+		if source := volume.ConfigMap; source != nil {
+			sources++
+			if source.Name == "" {
+				return newFieldError(field+".configMap.name", "is required")
+			}
+			if err := validateKeyToPaths(field+".configMap", source.Items); err != nil {
+				return err
+			}
+		}
+		if source := volume.Secret; source != nil {
+			sources++
+			if source.SecretName == "" {
+				return newFieldError(field+".secret.secretName", "is required")
+			}
+			if err := validateKeyToPaths(field+".secret", source.Items); err != nil {
+				return err
+			}
+		}
+		if source := volume.PersistentVolumeClaim; source != nil {
+			sources++
+			if source.ClaimName == "" {
+				return newFieldError(field+".persistentVolumeClaim.claimName", "is required")
+			}
+		}
+		if source := volume.Projected; source != nil {
+			sources++
+			if len(source.Sources) == 0 {
+				return newFieldError(field+".projected.sources", "at least one source is required")
+			}
+		}
+		if sources != 1 {
+			return newFieldError(field, "must set exactly one of emptyDir, configMap, secret, persistentVolumeClaim or projected")
+		}
+	}
+
+	return nil
+}
+
+// Validate the keys of a ConfigMap or Secret that are projected into a volume as files
+func validateKeyToPaths(field string, items []apiv1.KeyToPath) error {
+	for i, item := range items {
+		itemField := fmt.Sprintf("%s.items[%d]", field, i)
+		if item.Key == "" {
+			return newFieldError(itemField+".key", "is required")
+		}
+		if err := validateRelativePath(itemField+".path", item.Path, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate the volume mounts of a container against the volumes of the pod - field is the path of the container in the request
+func validateVolumeMounts(field string, mounts []apiv1.VolumeMount, volumes []apiv1.Volume) error {
+	mountPaths := map[string]bool{}
+	for i, mount := range mounts {
+		mountField := fmt.Sprintf("%svolumeMounts[%d]", field, i)
+		if !hasVolume(volumes, mount.Name) {
+			return newFieldError(mountField+".name", "no volume named %q in the deployment", mount.Name)
+		}
+		if !strings.HasPrefix(mount.MountPath, "/") {
+			return newFieldError(mountField+".mountPath", "must be an absolute path, got %q", mount.MountPath)
+		}
+		mountPath := path.Clean(mount.MountPath)
+		if mountPaths[mountPath] {
+			return newFieldError(mountField+".mountPath", "%q is mounted more than once", mount.MountPath)
+		}
+		mountPaths[mountPath] = true
+		if err := validateRelativePath(mountField+".subPath", mount.SubPath, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check that every volume mount in the pod still refers to a volume - used when the volumes are replaced in an update
+func validatePodVolumeMounts(podSpec *apiv1.PodSpec) error {
+	containers := append(append([]apiv1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, container := range containers {
+		for _, mount := range container.VolumeMounts {
+			if !hasVolume(podSpec.Volumes, mount.Name) {
+				return newFieldError("volumes", "volume %q is still mounted by container %q", mount.Name, container.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasVolume(volumes []apiv1.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate a path inside a volume - it must be relative and can't reach outside the volume with ".."
+func validateRelativePath(field string, value string, required bool) error {
+	if value == "" {
+		if required {
+			return newFieldError(field, "is required")
+		}
+		return nil
+	}
+	if strings.HasPrefix(value, "/") {
+		return newFieldError(field, "must be a relative path, got %q", value)
+	}
+	for _, segment := range strings.Split(value, "/") {
+		if segment == ".." {
+			return newFieldError(field, "can't contain '..', got %q", value)
+		}
+	}
+
+	return nil
+}
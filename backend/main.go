@@ -1,6 +1,8 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/Ajsalemo/kubernetes-client-application/config"
 	controllers "github.com/Ajsalemo/kubernetes-client-application/controllers"
 	"github.com/gofiber/fiber/v2"
@@ -12,18 +14,71 @@ func init() {
 	zap.ReplaceGlobals(zap.Must(zap.NewProduction()))
 }
 
+// Register the deployment, pod and manifest routes on the given router
+func registerRoutes(router fiber.Router) {
+	router.Post("/deployment/create", controllers.CreateDeployment)
+	router.Delete("/deployment/delete/:deployment", controllers.DeleteDeployment)
+	router.Patch("/deployment/:deployment", controllers.UpdateDeployment)
+	router.Put("/deployment/:deployment/scale", controllers.ScaleDeployment)
+	router.Post("/deployment/:deployment/diff", controllers.DiffDeployment)
+	router.Post("/deployment/:deployment/clone", controllers.CloneDeployment)
+	router.Get("/deployment/:deployment/rollout/status", controllers.RolloutStatus)
+	router.Get("/deployment/:deployment/rollout/history", controllers.RolloutHistory)
+	router.Post("/deployment/:deployment/rollout/undo", controllers.RolloutUndo)
+	router.Post("/deployment/:deployment/rollout/restart", controllers.RolloutRestart)
+	router.Post("/deployment/:deployment/rollout/pause", controllers.RolloutPause)
+	router.Post("/deployment/:deployment/rollout/resume", controllers.RolloutResume)
+	router.Get("/deployment/list", controllers.ListDeployments)
+	router.Get("/deployment/get/:deployment", controllers.GetDeployments)
+	router.Get("/deployment/list/:deployment/pods/:label", controllers.GetPods)
+	router.Get("/deployment/get/:deployment/pod/:pod", controllers.GetSpecificPod)
+	router.Delete("/deployment/pod/delete/:pod", controllers.DeleteSpecificPod)
+	router.Post("/apply", controllers.ApplyManifests)
+	// Templates are shared by every cluster, but are instantiated in the request's context and namespace
+	router.Post("/templates/:name/instantiate", controllers.InstantiateTemplate)
+}
+
+// Register the routes for a cluster, with and without a namespace segment
+func registerClusterRoutes(router fiber.Router) {
+	router.Get("/namespaces", controllers.ListNamespaces)
+	// Routes without a namespace segment target the "default" namespace
+	registerRoutes(router)
+	// The same routes scoped to a namespace - e.g. /api/namespaces/my-team/deployment/list
+	registerRoutes(router.Group("/namespaces/:namespace"))
+}
+
 func main() {
+	kubeconfig := flag.String("kubeconfig", "", "Path to a kubeconfig file - takes precedence over the KUBECONFIG environment variable and $HOME/.kube/config")
+	templates := flag.String("templates", "templates.json", "Path to the JSON file deployment templates are stored in - it's created when the first template is saved")
+	flag.Parse()
+	config.SetKubeConfigPath(*kubeconfig)
+	// The kubeconfig is only read here - a kubeconfig that can't be loaded is returned as the error of each request, the same as an unreachable cluster
+	if err := config.LoadKubeConfig(); err != nil {
+		zap.L().Error(err.Error())
+	}
+	config.LogKubeConfigLocation()
+	if err := config.LoadTemplates(*templates); err != nil {
+		zap.L().Fatal(err.Error())
+	}
+	zap.L().Info("Deployment templates are stored in " + *templates)
+
 	app := fiber.New()
 	app.Use(cors.New())
 
-	app.Post("/api/deployment/create", controllers.CreateDeployment)
-	app.Delete("/api/deployment/delete/:deployment", controllers.DeleteDeployment)
-	app.Get("/api/deployment/list", controllers.ListDeployments)
-	app.Get("/api/deployment/get/:deployment", controllers.GetDeployments)
-	app.Get("/api/deployment/list/:deployment/pods/:label", controllers.GetPods)
-	app.Get("/api/deployment/get/:deployment/pod/:pod", controllers.GetSpecificPod)
-	app.Delete("/api/deployment/pod/delete/:pod", controllers.DeleteSpecificPod)
+	app.Get("/api/contexts", controllers.ListContexts)
+	app.Get("/api/templates", controllers.ListTemplates)
+	app.Post("/api/templates", controllers.CreateTemplate)
+	app.Get("/api/templates/:name", controllers.GetTemplate)
+	app.Put("/api/templates/:name", controllers.UpdateTemplate)
+	app.Delete("/api/templates/:name", controllers.DeleteTemplate)
+	// Routes without a context segment target the context from the X-Kube-Context header, or the kubeconfig current context if it isn't set
+	api := app.Group("/api")
+	registerClusterRoutes(api)
+	// The same routes scoped to a kubeconfig context - e.g. /api/contexts/staging/deployment/list
+	registerClusterRoutes(api.Group("/contexts/:context"))
+
 	// Check if .kubeconfig is accessible at startup
+	// This creates the long-lived client for the current context - the informers of each namespace are started on its first request
 	_, kubeErr := config.KubeConfig()
 	if kubeErr != nil {
 		zap.L().Error(kubeErr.Error())